@@ -1,24 +1,333 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
+// store holds the colony objects the mock server can be queried for,
+// keyed by collection (functions, executors, cronjobs) and name.
+type store struct {
+	mu      sync.Mutex
+	objects map[string]map[string]map[string]interface{}
+	// graphs are the submitted workflows, keyed by process graph ID
+	graphs map[string]*graph
+	// processes are created by workflow submissions, keyed by ID
+	processes map[string]*process
+	// sim controls how processes progress
+	sim simulation
+	// restIdempotency and rpcIdempotency replay responses to retried
+	// submissions. The transports answer in different formats, so each
+	// has its own store.
+	restIdempotency *idempotency
+	rpcIdempotency  *idempotency
+	// verifier checks the signatures of RPC envelopes
+	verifier *verifier
+}
+
+func newStore(idempotencyTTL time.Duration) *store {
+	return &store{
+		objects: map[string]map[string]map[string]interface{}{
+			"functions": {},
+			"executors": {},
+			"cronjobs":  {},
+		},
+		graphs:          map[string]*graph{},
+		processes:       map[string]*process{},
+		restIdempotency: newIdempotency(idempotencyTTL),
+		rpcIdempotency:  newIdempotency(idempotencyTTL),
+	}
+}
+
+// nameOf returns the name an object is looked up by.
+func nameOf(obj map[string]interface{}) string {
+	for _, key := range []string{"name", "funcName", "executorName"} {
+		if n, ok := obj[key].(string); ok && n != "" {
+			return n
+		}
+	}
+	return ""
+}
+
+func (s *store) put(collection string, obj map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[collection][nameOf(obj)] = obj
+}
+
+// seed loads objects from a JSON file shaped like
+// {"functions": [...], "executors": [...], "cronjobs": [...]}.
+func (s *store) seed(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var seed map[string][]map[string]interface{}
+	if err := json.Unmarshal(data, &seed); err != nil {
+		return fmt.Errorf("failed to parse seed file: %w", err)
+	}
+	for collection, objs := range seed {
+		if _, ok := s.objects[collection]; !ok {
+			return fmt.Errorf("unknown collection %q in seed file", collection)
+		}
+		for _, obj := range objs {
+			s.put(collection, obj)
+		}
+	}
+	return nil
+}
+
+// handleFunctions serves lookups of functions, by name or by ?id=<functionId>,
+// and accepts registrations and removals.
+func (s *store) handleFunctions(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost:
+		s.handleRegister(w, r)
+	case r.Method == http.MethodDelete:
+		fmt.Printf("Received %s request to %s\n", r.Method, r.URL.RequestURI())
+		removed(w, s.removeFunction(r.URL.Query().Get("id")))
+	case r.Method == http.MethodGet && r.URL.Query().Has("id"):
+		fmt.Printf("Received %s request to %s\n", r.Method, r.URL.RequestURI())
+		f := s.function(r.URL.Query().Get("id"))
+		if f == nil {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(f)
+	default:
+		s.handleRead("functions")(w, r)
+	}
+}
+
+// function returns the function with the given function ID, or nil.
+func (s *store) function(id string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.objects["functions"] {
+		if f["functionId"] == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// removeFunction deletes the function with the given function ID.
+func (s *store) removeFunction(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, f := range s.objects["functions"] {
+		if f["functionId"] == id {
+			delete(s.objects["functions"], name)
+			return true
+		}
+	}
+	return false
+}
+
+// removed answers a DELETE request, with 404 if there was nothing to remove.
+func removed(w http.ResponseWriter, ok bool) {
+	if !ok {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"removed"}`))
+}
+
+// handleRegister serves POST /api/functions. It stores the function spec
+// under a new function ID and returns {"functionId": "<id>"}.
+func (s *store) handleRegister(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("Received %s request to %s\n", r.Method, r.URL.Path)
+	body, _ := io.ReadAll(r.Body)
+	fmt.Printf("Body: %s\n", string(body))
+
+	var spec map[string]interface{}
+	if err := json.Unmarshal(body, &spec); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid function spec: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if nameOf(spec) == "" {
+		http.Error(w, `{"error":"function spec has no funcName"}`, http.StatusBadRequest)
+		return
+	}
+
+	spec["functionId"] = newID()
+	s.put("functions", spec)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"functionId": spec["functionId"].(string)})
+}
+
+// handleWorkflows creates a process graph for a submitted workflow spec,
+// like submitworkflowspecmsg, and returns {"processGraphId": "<id>"}.
+// DELETE ?id=<processGraphId> removes the process graph.
+func (s *store) handleWorkflows(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		fmt.Printf("Received %s request to %s\n", r.Method, r.URL.RequestURI())
+		removed(w, s.removeProcessGraph(r.URL.Query().Get("id")))
+		return
+	}
+
+	fmt.Printf("Received %s request to %s\n", r.Method, r.URL.Path)
+	fmt.Printf("Headers: %v\n", r.Header)
+	body, _ := io.ReadAll(r.Body)
+	fmt.Printf("Body: %s\n", string(body))
+
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(body, &spec); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid workflow spec: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	graph := s.submitWorkflow(spec)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "submitted",
+		"processGraphId": graph.id,
+		"processIds":     graph.processIDs,
+	})
+}
+
+// list returns the objects of a collection.
+func (s *store) list(collection string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked(collection)
+}
+
+func (s *store) listLocked(collection string) []map[string]interface{} {
+	list := []map[string]interface{}{}
+	for _, obj := range s.objects[collection] {
+		list = append(list, obj)
+	}
+	return list
+}
+
+// handleRead serves GET /api/<collection>?name=X with the named object, or
+// the whole collection if no name is given.
+func (s *store) handleRead(collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("Received %s request to %s\n", r.Method, r.URL.RequestURI())
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			json.NewEncoder(w).Encode(s.listLocked(collection))
+			return
+		}
+
+		obj, ok := s.objects[collection][name]
+		if !ok {
+			http.Error(w, fmt.Sprintf(`{"error":"%s %s not found"}`, collection, name), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(obj)
+	}
+}
+
 func main() {
-	http.HandleFunc("/api/workflows", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("Received %s request to %s\n", r.Method, r.URL.Path)
-		fmt.Printf("Headers: %v\n", r.Header)
-		body, _ := io.ReadAll(r.Body)
-		fmt.Printf("Body: %s\n", string(body))
-
-		// Return success
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"submitted"}`))
+	port := flag.Int("port", 50080, "Port to listen on")
+	seedPath := flag.String("seed", "", "JSON file with functions, executors and cronjobs to serve")
+	unavailable := flag.Int("unavailable", 0, "Fail the first N requests with 503 to test retries")
+	useTLS := flag.Bool("tls", false, "Serve HTTPS")
+	certFile := flag.String("cert", "", "Server certificate file (a self-signed one is generated if empty)")
+	keyFile := flag.String("key", "", "Server key file")
+	certOut := flag.String("cert-out", "mock-server.pem", "Where to write the generated self-signed certificate")
+	clientCA := flag.String("client-ca", "", "CA file for client certificates; enables mutual TLS")
+	trustedKey := flag.String("pubkey", "", "Hex Ed25519 public key accepted for colonies without a -colony-key")
+	colonyKeys := map[string]ed25519.PublicKey{}
+	flag.Func("colony-key", "Register a colony's public key as colonyid=hexkey (repeatable)", func(arg string) error {
+		colonyID, hexKey, ok := strings.Cut(arg, "=")
+		if !ok || colonyID == "" {
+			return fmt.Errorf("want colonyid=hexkey")
+		}
+		key, err := parsePublicKey(hexKey)
+		if err != nil {
+			return err
+		}
+		colonyKeys[colonyID] = key
+		return nil
 	})
+	allowUnsigned := flag.Bool("allow-unsigned", false, "Accept requests without a signature")
+	processTime := flag.Duration("process-time", 2*time.Second, "How long a submitted process takes: it waits for half of it and runs for the rest")
+	failFuncs := flag.String("fail", "", "Comma separated function names whose processes fail")
+	idempotencyTTL := flag.Duration("idempotency-ttl", DefaultIdempotencyTTL, "How long a response is replayed for its idempotency key")
+	flag.Parse()
+
+	var anyColony ed25519.PublicKey
+	if *trustedKey != "" {
+		key, err := parsePublicKey(*trustedKey)
+		if err != nil {
+			log.Fatalf("Invalid -pubkey: %v", err)
+		}
+		anyColony = key
+	}
+	if anyColony == nil && len(colonyKeys) == 0 {
+		fmt.Println("No public keys registered: signed requests are rejected, use -colony-key or -pubkey")
+	}
+	v := newVerifier(colonyKeys, anyColony, *allowUnsigned)
+
+	s := newStore(*idempotencyTTL)
+	s.verifier = v
+	s.sim.duration = *processTime
+	s.sim.fail = map[string]bool{}
+	for _, name := range strings.Split(*failFuncs, ",") {
+		if name != "" {
+			s.sim.fail[name] = true
+		}
+	}
+	if *seedPath != "" {
+		if err := s.seed(*seedPath); err != nil {
+			log.Fatalf("Failed to load seed: %v", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	// Signatures are checked before an idempotent response is replayed, so
+	// a replayed request is rejected rather than answered
+	mux.HandleFunc("/api/workflows", v.wrap(s.restIdempotency.wrap(s.handleWorkflows)))
+
+	// RPC envelopes are verified by handleRPC
+	mux.HandleFunc("/api", s.handleRPC)
+	mux.HandleFunc("/api/functions", v.wrap(s.restIdempotency.wrap(s.handleFunctions)))
+	mux.HandleFunc("/api/processgraphs", v.wrap(s.handleProcessGraphs))
+	for _, collection := range []string{"executors", "cronjobs"} {
+		mux.HandleFunc("/api/"+collection, v.wrap(s.handleRead(collection)))
+	}
+
+	addr := fmt.Sprintf(":%d", *port)
+	handler := flaky(*unavailable, mux)
+	if !*useTLS {
+		fmt.Printf("Mock ColonyOS server listening on %s\n", addr)
+		log.Fatal(http.ListenAndServe(addr, handler))
+	}
 
-	fmt.Println("Mock ColonyOS server listening on :50080")
-	log.Fatal(http.ListenAndServe(":50080", nil))
+	config, err := tlsConfig(*certFile, *keyFile, *certOut, *clientCA)
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: config}
+	fmt.Printf("Mock ColonyOS server listening on %s (TLS)\n", addr)
+	log.Fatal(server.ListenAndServeTLS("", ""))
 }
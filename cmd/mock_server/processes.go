@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// graph is a submitted workflow.
+type graph struct {
+	id         string
+	processIDs []string
+}
+
+// process is a process of a submitted workflow. Its state is simulated
+// from the time since it was submitted, see simulate.
+type process struct {
+	id        string
+	graphID   string
+	name      string
+	spec      interface{}
+	submitted time.Time
+}
+
+// simulation controls how processes progress. A process waits for the
+// first half of duration, runs for the second half and then succeeds, or
+// fails if its function is in fail.
+type simulation struct {
+	duration time.Duration
+	fail     map[string]bool
+}
+
+// submitWorkflow creates a process graph with one process per function
+// spec of the workflow, or a single process if it has none.
+func (s *store) submitWorkflow(spec map[string]interface{}) *graph {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := &graph{id: newID(), processIDs: []string{}}
+	functionSpecs, _ := spec["functionspecs"].([]interface{})
+	if len(functionSpecs) == 0 {
+		functionSpecs = []interface{}{spec}
+	}
+
+	now := time.Now()
+	for _, fs := range functionSpecs {
+		p := &process{
+			id:        newID(),
+			graphID:   g.id,
+			spec:      fs,
+			submitted: now,
+		}
+		if m, ok := fs.(map[string]interface{}); ok {
+			p.name = processName(m)
+		}
+		s.processes[p.id] = p
+		g.processIDs = append(g.processIDs, p.id)
+	}
+	s.graphs[g.id] = g
+	return g
+}
+
+// processName returns the function a process runs.
+func processName(spec map[string]interface{}) string {
+	for _, key := range []string{"funcname", "funcName", "nodename", "name"} {
+		if n, ok := spec[key].(string); ok && n != "" {
+			return n
+		}
+	}
+	return ""
+}
+
+// removeProcessGraph deletes a process graph and its processes.
+func (s *store) removeProcessGraph(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.graphs[id]
+	if !ok {
+		return false
+	}
+	for _, pid := range g.processIDs {
+		delete(s.processes, pid)
+	}
+	delete(s.graphs, id)
+	return true
+}
+
+func (s *store) process(id string) (*process, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.processes[id]
+	return p, ok
+}
+
+// simulate returns the state of p at now.
+func (s *store) simulate(p *process, now time.Time) domain.Process {
+	state := domain.Process{ID: p.id, Name: p.name, State: domain.ProcessWaiting}
+	started := p.submitted.Add(s.sim.duration / 2)
+	ended := p.submitted.Add(s.sim.duration)
+	if !now.Before(started) {
+		state.State = domain.ProcessRunning
+		state.ExecutorID = "mock-executor"
+		state.StartTime = started
+	}
+	if !now.Before(ended) {
+		state.State = domain.ProcessSuccessful
+		state.EndTime = ended
+		if s.sim.fail[p.name] {
+			state.State = domain.ProcessFailed
+			state.Errors = []string{fmt.Sprintf("%s exited with status 1", p.name)}
+		}
+	}
+	return state
+}
+
+// processGraph returns the simulated state of a process graph at now, or
+// nil if it does not exist.
+func (s *store) processGraph(id string, now time.Time) *domain.ProcessGraph {
+	s.mu.Lock()
+	g, ok := s.graphs[id]
+	var procs []*process
+	if ok {
+		for _, pid := range g.processIDs {
+			procs = append(procs, s.processes[pid])
+		}
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	result := &domain.ProcessGraph{ID: id, Processes: []domain.Process{}}
+	for _, p := range procs {
+		result.Processes = append(result.Processes, s.simulate(p, now))
+	}
+	result.State = domain.GraphState(result.Processes)
+	return result
+}
+
+// graphMsg is a process graph as sent in RPC messages, or nil if it does
+// not exist.
+func (s *store) graphMsg(id string, now time.Time) map[string]interface{} {
+	state := s.processGraph(id, now)
+	if state == nil {
+		return nil
+	}
+	s.mu.Lock()
+	ids := s.graphs[id].processIDs
+	s.mu.Unlock()
+	return map[string]interface{}{
+		"processgraphid": id,
+		"state":          state.State,
+		"processids":     ids,
+	}
+}
+
+// msg is the process as sent in RPC messages, with the simulated state.
+func (p *process) msg(state domain.Process) map[string]interface{} {
+	// Clients name a process by the funcname of its spec, which a submitted
+	// spec may only give as funcName or name
+	spec := map[string]interface{}{}
+	if m, ok := p.spec.(map[string]interface{}); ok {
+		maps.Copy(spec, m)
+	}
+	if _, ok := spec["funcname"]; !ok && p.name != "" {
+		spec["funcname"] = p.name
+	}
+
+	m := map[string]interface{}{
+		"processid":      p.id,
+		"processgraphid": p.graphID,
+		"state":          state.State,
+		"spec":           spec,
+	}
+	if state.ExecutorID != "" {
+		m["assignedexecutorid"] = state.ExecutorID
+	}
+	if !state.StartTime.IsZero() {
+		m["starttime"] = state.StartTime
+	}
+	if !state.EndTime.IsZero() {
+		m["endtime"] = state.EndTime
+	}
+	if len(state.Errors) > 0 {
+		m["errors"] = state.Errors
+	}
+	return m
+}
+
+// handleProcessGraphs serves GET /api/processgraphs?id=X with the state
+// of the process graph and its processes.
+func (s *store) handleProcessGraphs(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("Received %s request to %s\n", r.Method, r.URL.RequestURI())
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	g := s.processGraph(r.URL.Query().Get("id"), time.Now())
+	if g == nil {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g)
+}
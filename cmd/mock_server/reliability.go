@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long a response is kept for its
+// idempotency key.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// recorded is a response kept for an idempotency key.
+type recorded struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// idempotency replays the first response to a request for every later
+// request with the same idempotency key, so retried submissions are not
+// stored twice. Responses are forgotten after ttl.
+type idempotency struct {
+	mu        sync.Mutex
+	responses map[string]recorded
+	ttl       time.Duration
+}
+
+func newIdempotency(ttl time.Duration) *idempotency {
+	return &idempotency{responses: map[string]recorded{}, ttl: ttl}
+}
+
+// wrap handles requests carrying an Idempotency-Key header. The key of an
+// RPC message is inside its payload, so handleRPC uses lookup and save.
+func (i *idempotency) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		if i.replay(w, key) {
+			return
+		}
+		rec := httptest.NewRecorder()
+		next(rec, r)
+		i.save(key, rec)
+		copyResponse(w, rec.Code, rec.Header(), rec.Body.Bytes())
+	}
+}
+
+// replay writes the response recorded for key, if there is one.
+func (i *idempotency) replay(w http.ResponseWriter, key string) bool {
+	i.mu.Lock()
+	resp, ok := i.responses[key]
+	i.mu.Unlock()
+	if !ok || time.Now().After(resp.expires) {
+		return false
+	}
+	fmt.Printf("Replaying response for idempotency key %s\n", key)
+	copyResponse(w, resp.status, resp.header, resp.body)
+	return true
+}
+
+// save records a successful response for key. Failed requests are not
+// recorded, so they can be retried.
+func (i *idempotency) save(key string, rec *httptest.ResponseRecorder) {
+	if rec.Code >= 400 {
+		return
+	}
+	now := time.Now()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for k, resp := range i.responses {
+		if now.After(resp.expires) {
+			delete(i.responses, k)
+		}
+	}
+	i.responses[key] = recorded{
+		status:  rec.Code,
+		header:  rec.Header().Clone(),
+		body:    rec.Body.Bytes(),
+		expires: now.Add(i.ttl),
+	}
+}
+
+func copyResponse(w http.ResponseWriter, status int, header http.Header, body []byte) {
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// flaky fails the first n requests with 503 and Retry-After: 1, to test
+// client retries.
+func flaky(n int, next http.Handler) http.Handler {
+	var mu sync.Mutex
+	failed := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fail := failed < n
+		if fail {
+			failed++
+		}
+		mu.Unlock()
+
+		if fail {
+			fmt.Printf("Failing %s %s with 503 (%d/%d)\n", r.Method, r.URL.Path, failed, n)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// rpcMsg is the ColonyOS RPC envelope, see colony.RPCMsg.
+type rpcMsg struct {
+	Signature   string `json:"signature"`
+	PublicKey   string `json:"publickey,omitempty"`
+	PayloadType string `json:"payloadtype"`
+	Payload     string `json:"payload"`
+	Error       bool   `json:"error"`
+}
+
+// newID returns a random 64 character hex ID, like ColonyOS IDs.
+func newID() string {
+	id := make([]byte, 32)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// handleRPC serves POST /api with ColonyOS RPC envelopes. It supports
+// submitworkflowspecmsg, addfunctionmsg, getprocessmsg,
+// getprocessgraphmsg, getfunctionmsg, removeprocessgraphmsg,
+// removefunctionmsg and the lookups getfunctionsmsg, getexecutorsmsg and
+// getcronsmsg.
+func (s *store) handleRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var msg rpcMsg
+	if err := json.Unmarshal(body, &msg); err != nil {
+		replyError(w, http.StatusBadRequest, "invalid RPC envelope: %v", err)
+		return
+	}
+	if err := s.verifier.verifyRPC(msg); err != nil {
+		fmt.Printf("Rejected RPC %s: %v\n", msg.PayloadType, err)
+		replyError(w, http.StatusUnauthorized, "%v", err)
+		return
+	}
+	payload, err := base64.StdEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		replyError(w, http.StatusBadRequest, "invalid payload encoding: %v", err)
+		return
+	}
+	fmt.Printf("Received RPC %s: %s\n", msg.PayloadType, string(payload))
+
+	var keyed struct {
+		IdempotencyKey string `json:"idempotencykey"`
+	}
+	json.Unmarshal(payload, &keyed)
+	if keyed.IdempotencyKey != "" {
+		if s.rpcIdempotency.replay(w, keyed.IdempotencyKey) {
+			return
+		}
+		rec := httptest.NewRecorder()
+		s.dispatchRPC(rec, msg.PayloadType, payload)
+		s.rpcIdempotency.save(keyed.IdempotencyKey, rec)
+		copyResponse(w, rec.Code, rec.Header(), rec.Body.Bytes())
+		return
+	}
+	s.dispatchRPC(w, msg.PayloadType, payload)
+}
+
+// dispatchRPC handles a decoded RPC message by type.
+func (s *store) dispatchRPC(w http.ResponseWriter, payloadType string, payload []byte) {
+
+	switch payloadType {
+	case "submitworkflowspecmsg":
+		var req struct {
+			Spec map[string]interface{} `json:"spec"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil || req.Spec == nil {
+			replyError(w, http.StatusBadRequest, "invalid workflow spec")
+			return
+		}
+		graph := s.submitWorkflow(req.Spec)
+		reply(w, "processgraphmsg", map[string]interface{}{"processgraph": s.graphMsg(graph.id, time.Now())})
+
+	case "addfunctionmsg":
+		var req struct {
+			Function map[string]interface{} `json:"function"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil || req.Function == nil {
+			replyError(w, http.StatusBadRequest, "invalid function spec")
+			return
+		}
+		if nameOf(req.Function) == "" {
+			replyError(w, http.StatusBadRequest, "function spec has no funcName")
+			return
+		}
+		req.Function["functionId"] = newID()
+		s.put("functions", req.Function)
+		reply(w, "functionmsg", map[string]interface{}{"function": req.Function})
+
+	case "getprocessmsg":
+		var req struct {
+			ProcessID string `json:"processid"`
+		}
+		json.Unmarshal(payload, &req)
+		p, ok := s.process(req.ProcessID)
+		if !ok {
+			replyError(w, http.StatusNotFound, "process %s not found", req.ProcessID)
+			return
+		}
+		reply(w, "processmsg", map[string]interface{}{"process": p.msg(s.simulate(p, time.Now()))})
+
+	case "getprocessgraphmsg":
+		var req struct {
+			ProcessGraphID string `json:"processgraphid"`
+		}
+		json.Unmarshal(payload, &req)
+		graph := s.graphMsg(req.ProcessGraphID, time.Now())
+		if graph == nil {
+			replyError(w, http.StatusNotFound, "process graph %s not found", req.ProcessGraphID)
+			return
+		}
+		reply(w, "processgraphmsg", map[string]interface{}{"processgraph": graph})
+
+	case "getfunctionmsg":
+		var req struct {
+			FunctionID string `json:"functionid"`
+		}
+		json.Unmarshal(payload, &req)
+		f := s.function(req.FunctionID)
+		if f == nil {
+			replyError(w, http.StatusNotFound, "function %s not found", req.FunctionID)
+			return
+		}
+		reply(w, "functionmsg", map[string]interface{}{"function": f})
+
+	case "getfunctionsmsg", "getexecutorsmsg", "getcronsmsg":
+		collections := map[string]struct{ collection, reply, key string }{
+			"getfunctionsmsg": {"functions", "functionsmsg", "functions"},
+			"getexecutorsmsg": {"executors", "executorsmsg", "executors"},
+			"getcronsmsg":     {"cronjobs", "cronsmsg", "crons"},
+		}
+		c := collections[payloadType]
+		reply(w, c.reply, map[string]interface{}{c.key: s.list(c.collection)})
+
+	case "removeprocessgraphmsg":
+		var req struct {
+			ProcessGraphID string `json:"processgraphid"`
+		}
+		json.Unmarshal(payload, &req)
+		if !s.removeProcessGraph(req.ProcessGraphID) {
+			replyError(w, http.StatusNotFound, "process graph %s not found", req.ProcessGraphID)
+			return
+		}
+		reply(w, payloadType, map[string]interface{}{})
+
+	case "removefunctionmsg":
+		var req struct {
+			FunctionID string `json:"functionid"`
+		}
+		json.Unmarshal(payload, &req)
+		if !s.removeFunction(req.FunctionID) {
+			replyError(w, http.StatusNotFound, "function %s not found", req.FunctionID)
+			return
+		}
+		reply(w, payloadType, map[string]interface{}{})
+
+	default:
+		replyError(w, http.StatusBadRequest, "unsupported message type %q", payloadType)
+	}
+}
+
+func reply(w http.ResponseWriter, payloadType string, v interface{}) {
+	data, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rpcMsg{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(data),
+	})
+}
+
+func replyError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"message": fmt.Sprintf(format, args...),
+		"status":  status,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rpcMsg{
+		PayloadType: "error",
+		Payload:     base64.StdEncoding.EncodeToString(data),
+		Error:       true,
+	})
+}
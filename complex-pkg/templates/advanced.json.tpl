@@ -1,6 +1,6 @@
 {
-    "name": "{{ required "name is required" .Values.name }}",
-    "env": "{{ .Values.environment | upper }}",
+    "name": {{ required "name is required" .Values.name | quoteJson }},
+    "env": {{ .Values.environment | upper | quoteJson }},
     "config_dump": {{ .Values.config | toJson }},
     "feature_count": {{ len .Values.config.features }}
 }
\ No newline at end of file
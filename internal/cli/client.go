@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/colonyos/cpm/internal/infra/colony"
+	"github.com/colonyos/cpm/pkg/domain"
+	"github.com/spf13/pflag"
+)
+
+// connectionOptions holds the flags used to connect to a colony. Flags that
+// are not given fall back to the selected context in config.yaml.
+type connectionOptions struct {
+	context    string
+	host       string
+	port       int
+	colonyID   string
+	prvKey     string
+	retries    int
+	retryDelay time.Duration
+	tls        bool
+	caFile     string
+	certFile   string
+	keyFile    string
+	insecure   bool
+}
+
+func (o *connectionOptions) addFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.context, "context", "", "Connection context from config.yaml (defaults to currentContext)")
+	fs.StringVar(&o.host, "host", "localhost", "ColonyOS server host")
+	fs.IntVar(&o.port, "port", 50080, "ColonyOS server port")
+	fs.StringVar(&o.colonyID, "colonyid", "", "Colony ID (required)")
+	fs.StringVar(&o.prvKey, "prvkey", "", "Private Key (required)")
+	fs.IntVar(&o.retries, "retries", colony.DefaultRetryPolicy.MaxRetries, "Retries for requests that fail with a network error, 429 or 5xx")
+	fs.DurationVar(&o.retryDelay, "retry-delay", colony.DefaultRetryPolicy.BaseDelay, "Backoff before the first retry, doubled on every retry")
+	fs.BoolVar(&o.tls, "tls", false, "Connect to the colony over HTTPS")
+	fs.StringVar(&o.caFile, "ca-file", "", "PEM file of CA certificates to trust for the colony server (implies --tls)")
+	fs.StringVar(&o.certFile, "cert", "", "Client certificate file for mutual TLS (implies --tls)")
+	fs.StringVar(&o.keyFile, "key", "", "Client key file for mutual TLS (implies --tls)")
+	fs.BoolVar(&o.insecure, "insecure-skip-verify", false, "Do not verify the colony server certificate (implies --tls)")
+}
+
+// resolve applies the selected context from the config to the options,
+// except for flags set on the command line, which take precedence.
+func (o *connectionOptions) resolve(fs *pflag.FlagSet, cfg *Config) error {
+	name := o.context
+	if name == "" {
+		name = cfg.CurrentContext
+	}
+	if name == "" {
+		return nil
+	}
+	ctx, ok := cfg.Contexts[name]
+	if !ok {
+		return fmt.Errorf("context %q not found in config.yaml", name)
+	}
+
+	setString := func(flag string, dst *string, v string) {
+		if v != "" && !fs.Changed(flag) {
+			*dst = v
+		}
+	}
+	setBool := func(flag string, dst *bool, v bool) {
+		if v && !fs.Changed(flag) {
+			*dst = v
+		}
+	}
+	setString("host", &o.host, ctx.Host)
+	setString("colonyid", &o.colonyID, ctx.ColonyID)
+	setString("ca-file", &o.caFile, ctx.CAFile)
+	setString("cert", &o.certFile, ctx.CertFile)
+	setString("key", &o.keyFile, ctx.KeyFile)
+	setBool("tls", &o.tls, ctx.TLS)
+	setBool("insecure-skip-verify", &o.insecure, ctx.InsecureSkipVerify)
+	if ctx.Port != 0 && !fs.Changed("port") {
+		o.port = ctx.Port
+	}
+	return nil
+}
+
+// configured reports whether the colony ID and private key are set, so a
+// real colony can be contacted.
+func (o *connectionOptions) configured() bool {
+	return o.colonyID != "" && o.prvKey != ""
+}
+
+// newColonyClient returns the colony client selected by the config,
+// connected and retrying as set by the options.
+func (o *connectionOptions) newColonyClient(cfg *Config) (domain.Submitter, error) {
+	retry := colony.DefaultRetryPolicy
+	retry.MaxRetries = o.retries
+	retry.BaseDelay = o.retryDelay
+
+	tlsConfig := colony.TLSConfig{
+		Enabled:            o.tls,
+		CAFile:             o.caFile,
+		CertFile:           o.certFile,
+		KeyFile:            o.keyFile,
+		InsecureSkipVerify: o.insecure,
+	}
+
+	var client domain.Submitter
+	var err error
+	switch cfg.Client {
+	case "", ClientHTTP:
+		client, err = colony.NewColonyClient(o.host, o.port, o.colonyID, o.prvKey).WithRetry(retry).WithTLS(tlsConfig)
+	case ClientRPC:
+		rpc, rpcErr := colony.NewRPCClient(o.host, o.port, o.colonyID, o.prvKey)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		client, err = rpc.WithRetry(retry).WithTLS(tlsConfig)
+	default:
+		return nil, fmt.Errorf("unknown client %q in config.yaml (use %s or %s)", cfg.Client, ClientHTTP, ClientRPC)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure TLS: %w", err)
+	}
+	return client, nil
+}
@@ -1,8 +1,43 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds user settings read from $CPM_HOME/config.yaml.
+type Config struct {
+	// DefaultEnv is the values profile used when --env is not given.
+	DefaultEnv string `yaml:"defaultEnv,omitempty"`
+	// Client selects how CPM talks to the colony: "http" (default) posts
+	// signed JSON to /api/<collection>, "rpc" sends ColonyOS RPC envelopes.
+	Client string `yaml:"client,omitempty"`
+	// CurrentContext is the context used when --context is not given
+	CurrentContext string `yaml:"currentContext,omitempty"`
+	// Contexts are named colony connections
+	Contexts map[string]Context `yaml:"contexts,omitempty"`
+}
+
+// Context holds the connection settings for a colony. Command line flags
+// override them.
+type Context struct {
+	Host               string `yaml:"host,omitempty"`
+	Port               int    `yaml:"port,omitempty"`
+	ColonyID           string `yaml:"colonyId,omitempty"`
+	TLS                bool   `yaml:"tls,omitempty"`
+	CAFile             string `yaml:"caFile,omitempty"`
+	CertFile           string `yaml:"certFile,omitempty"`
+	KeyFile            string `yaml:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty"`
+}
+
+// Colony clients that can be selected with the client setting
+const (
+	ClientHTTP = "http"
+	ClientRPC  = "rpc"
 )
 
 // GetCPMHome returns the path to the CPM state directory.
@@ -18,3 +53,21 @@ func GetCPMHome() (string, error) {
 	}
 	return filepath.Join(home, ".cpm"), nil
 }
+
+// LoadConfig reads config.yaml from the CPM home directory. A missing file
+// yields an empty config.
+func LoadConfig(cpmHome string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(filepath.Join(cpmHome, "config.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return cfg, nil
+}
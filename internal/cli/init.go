@@ -22,7 +22,7 @@ var initCmd = &cobra.Command{
 		pkgService := storage.NewFsPackageService()
 		uc := usecase.NewInitPackageUseCase(pkgService)
 		
-		err := uc.Execute(name)
+		err := uc.Execute(cmd.Context(), name)
 		if err != nil {
 			fmt.Printf("Error initializing package: %v\n", err)
 			return
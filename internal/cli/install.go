@@ -1,34 +1,40 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"os"
+	"time"
 
 	"github.com/colonyos/cpm/internal/engine"
 	"github.com/colonyos/cpm/internal/infra/colony"
 	"github.com/colonyos/cpm/internal/infra/registry"
 	"github.com/colonyos/cpm/internal/infra/storage"
+	"github.com/colonyos/cpm/internal/secrets"
 	"github.com/colonyos/cpm/internal/usecase"
 	"github.com/colonyos/cpm/pkg/domain"
 	"github.com/spf13/cobra"
 )
 
 var (
-	setFlags     []string
-	colonyHost   string
-	colonyPort   int
-	colonyID     string
-	colonyPrvKey string
-	cpmVersion   string
+	installValues valueOptions
+	installConn   connectionOptions
+	cpmVersion    string
+	installDryRun bool
+	installAtomic bool
+	installWait   bool
+	installPoll   time.Duration
 )
 
 func init() {
-	installCmd.Flags().StringArrayVar(&setFlags, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
-	installCmd.Flags().StringVar(&colonyHost, "host", "localhost", "ColonyOS server host")
-	installCmd.Flags().IntVar(&colonyPort, "port", 50080, "ColonyOS server port")
-	installCmd.Flags().StringVar(&colonyID, "colonyid", "", "Colony ID (required)")
-	installCmd.Flags().StringVar(&colonyPrvKey, "prvkey", "", "Private Key (required)")
+	installValues.addFlags(installCmd.Flags())
+	installConn.addFlags(installCmd.Flags())
 	installCmd.Flags().StringVar(&cpmVersion, "version", "", "Package version (required if installing from registry)")
+	installCmd.Flags().BoolVar(&installDryRun, "dry-run", false, "Render and print the specs without submitting them or querying the colony")
+	installCmd.Flags().BoolVar(&installAtomic, "atomic", false, "If a spec fails to submit, remove the resources already created")
+	installCmd.Flags().BoolVar(&installWait, "wait", false, "Wait for the submitted workflows to finish and fail if any of them fails (bounded by --timeout)")
+	installCmd.Flags().DurationVar(&installPoll, "poll-interval", usecase.DefaultPollInterval, "How often --wait polls the colony")
 
 	rootCmd.AddCommand(installCmd)
 }
@@ -50,6 +56,17 @@ var installCmd = &cobra.Command{
 			return
 		}
 
+		cfg, err := LoadConfig(cpmHome)
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+
+		if err := installConn.resolve(cmd.Flags(), cfg); err != nil {
+			fmt.Printf("Error resolving connection: %v\n", err)
+			return
+		}
+
 		stateService, err := storage.NewJSONStateService(cpmHome)
 		if err != nil {
 			fmt.Printf("Error initializing state service: %v\n", err)
@@ -64,38 +81,60 @@ var installCmd = &cobra.Command{
 
 		// Initialize ColonySDK (Real or Mock)
 		var sdk domain.Submitter
-		if colonyID != "" && colonyPrvKey != "" {
-			sdk = colony.NewColonyClient(colonyHost, colonyPort, colonyID, colonyPrvKey)
+		if installConn.configured() {
+			client, err := installConn.newColonyClient(cfg)
+			if err != nil {
+				fmt.Printf("Error initializing colony client: %v\n", err)
+				return
+			}
+			sdk = client
+			// Templates may query the colony with lookup, except in a dry run
+			if lookup, ok := client.(domain.ResourceLookup); ok && !installDryRun {
+				renderer = renderer.WithLookup(lookup)
+			}
 		} else {
 			// Fallback to MockSDK for testing or dry-run
 			sdk = colony.NewMockSDK()
-			if colonyID == "" || colonyPrvKey == "" {
+			if !installConn.configured() {
 				// Only warn if we are not testing (how to detect? maybe verbose flag?)
 				// For now just proceed
 			}
 		}
 
-		uc := usecase.NewInstallPackageUseCase(pkgService, renderer, sdk, stateService, regService)
+		keyring := secrets.NewKeyring(cpmHome)
 
-		overrides := make(map[string]interface{})
-		for _, s := range setFlags {
-			parts := strings.SplitN(s, "=", 2)
-			if len(parts) == 2 {
-				overrides[parts[0]] = parts[1]
-			}
+		uc := usecase.NewInstallPackageUseCase(pkgService, renderer, sdk, stateService, regService, keyring)
+
+		renderOpts, err := installValues.renderOptions(cfg, cpmVersion)
+		if err != nil {
+			fmt.Printf("Error parsing options: %v\n", err)
+			return
 		}
 
 		// Inject CLI flags into overrides if appropriate
-		if colonyID != "" {
-			overrides["colonyId"] = colonyID
+		if installConn.colonyID != "" {
+			renderOpts.Values["colonyId"] = installConn.colonyID
 		}
 
-		err = uc.Execute(path, overrides, cpmVersion)
+		err = uc.Execute(cmd.Context(), path, usecase.InstallOptions{
+			RenderOptions: renderOpts,
+			DryRun:        installDryRun,
+			Atomic:        installAtomic,
+			Wait:          installWait,
+			PollInterval:  installPoll,
+		})
 		if err != nil {
 			fmt.Printf("Error installing package: %v\n", err)
+			// An interrupt is reported by Execute
+			if installWait && !errors.Is(err, context.Canceled) {
+				os.Exit(1)
+			}
 			return
 		}
 
+		if installDryRun {
+			return
+		}
 		fmt.Println("Installation complete.")
 	},
 }
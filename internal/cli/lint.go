@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/infra/storage"
+	"github.com/colonyos/cpm/internal/usecase"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [path]",
+	Short: "Check a package for problems",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		pkgService := storage.NewFsPackageService()
+		renderer := engine.NewGoTemplateEngine()
+		uc := usecase.NewLintPackageUseCase(pkgService, renderer)
+
+		msgs, err := uc.Execute(cmd.Context(), path)
+		if err != nil {
+			fmt.Printf("Error linting package: %v\n", err)
+			os.Exit(1)
+		}
+
+		errors := 0
+		for _, m := range msgs {
+			fmt.Println(m)
+			if m.Severity == usecase.LintError {
+				errors++
+			}
+		}
+
+		if errors > 0 {
+			fmt.Printf("Lint failed: %d error(s), %d warning(s)\n", errors, len(msgs)-errors)
+			os.Exit(1)
+		}
+		fmt.Printf("Lint passed: %d warning(s)\n", len(msgs))
+	},
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/colonyos/cpm/internal/infra/storage"
 	"github.com/colonyos/cpm/internal/usecase"
+	"github.com/colonyos/cpm/pkg/domain"
 	"github.com/spf13/cobra"
 )
 
@@ -31,7 +32,7 @@ var listCmd = &cobra.Command{
 		}
 
 		uc := usecase.NewListPackagesUseCase(stateService)
-		releases, err := uc.Execute()
+		releases, err := uc.Execute(cmd.Context())
 		if err != nil {
 			fmt.Printf("Error listing packages: %v\n", err)
 			return
@@ -43,9 +44,13 @@ var listCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "NAME\tVERSION\tINSTALLED\tCOLONY_ID")
+		fmt.Fprintln(w, "NAME\tVERSION\tSTATUS\tINSTALLED\tCOLONY_ID")
 		for _, r := range releases {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.Version, r.InstallTime.Format("2006-01-02 15:04:05"), r.ColonyID)
+			status := r.Status
+			if status == "" {
+				status = domain.ReleaseDeployed
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Version, status, r.InstallTime.Format("2006-01-02 15:04:05"), r.ColonyID)
 		}
 		w.Flush()
 	},
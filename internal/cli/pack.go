@@ -24,7 +24,7 @@ var packCmd = &cobra.Command{
 		pkgService := storage.NewFsPackageService()
 		uc := usecase.NewPackPackageUseCase(pkgService)
 		
-		err := uc.Execute(dir)
+		err := uc.Execute(cmd.Context(), dir)
 		if err != nil {
 			fmt.Printf("Error packing package: %v\n", err)
 			return
@@ -36,7 +36,7 @@ var publishCmd = &cobra.Command{
 		}
 
 		uc := usecase.NewPublishPackageUseCase(pkgService, regService)
-		err = uc.Execute(path)
+		err = uc.Execute(cmd.Context(), path)
 		if err != nil {
 			fmt.Printf("Error publishing package: %v\n", err)
 			return
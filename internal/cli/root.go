@@ -1,25 +1,61 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	globalTimeout time.Duration
+	cancelTimeout context.CancelFunc = func() {}
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "cpm",
 	Short: "Colony Package Manager",
 	Long:  `CPM is a package manager for ColonyOS distributed applications`,
+	// Bound every command by --timeout
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if globalTimeout > 0 {
+			ctx, cancel := context.WithTimeout(cmd.Context(), globalTimeout)
+			cmd.SetContext(ctx)
+			cancelTimeout = cancel
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// Do Stuff Here
 		cmd.Help()
 	},
 }
 
+func init() {
+	rootCmd.PersistentFlags().DurationVar(&globalTimeout, "timeout", 0, "Abort the command after this duration, e.g. 30s or 10m (0 means no limit)")
+}
+
+// Execute runs the root command. The first Ctrl-C or SIGTERM cancels the
+// command's context, so requests in flight are aborted and an interrupted
+// install is recorded as failed; a second one exits immediately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(130)
+	}
 }
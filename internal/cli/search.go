@@ -32,7 +32,7 @@ var searchCmd = &cobra.Command{
 			return
 		}
 
-		results, err := regService.Search(query)
+		results, err := regService.Search(cmd.Context(), query)
 		if err != nil {
 			fmt.Printf("Error searching packages: %v\n", err)
 			return
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/colonyos/cpm/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretsDecryptInPlace bool
+
+func init() {
+	secretsDecryptCmd.Flags().BoolVarP(&secretsDecryptInPlace, "in-place", "i", false, "Write the decrypted values back to the file instead of printing them")
+
+	secretsCmd.AddCommand(secretsEncryptCmd)
+	secretsCmd.AddCommand(secretsDecryptCmd)
+	secretsCmd.AddCommand(secretsEditCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage encrypted package secrets",
+	Long:  `Encrypt, decrypt and edit secrets.yaml files. Keys are stored in $CPM_HOME/keys.`,
+}
+
+var secretsEncryptCmd = &cobra.Command{
+	Use:   "encrypt [file]",
+	Short: "Encrypt the plaintext values of a secrets file in place",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := secretsFileArg(args)
+
+		keyring, err := loadKeyring()
+		if err != nil {
+			fmt.Printf("Error getting CPM home directory: %v\n", err)
+			return
+		}
+
+		count, generated, err := keyring.EncryptFile(path)
+		if generated {
+			fmt.Printf("Generated new secrets key at %s\n", keyring.KeyPath())
+		}
+		if err != nil {
+			fmt.Printf("Error encrypting secrets: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Encrypted %d value(s) in %s\n", count, path)
+	},
+}
+
+var secretsDecryptCmd = &cobra.Command{
+	Use:   "decrypt [file]",
+	Short: "Print the decrypted values of a secrets file",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := secretsFileArg(args)
+
+		keyring, err := loadKeyring()
+		if err != nil {
+			fmt.Printf("Error getting CPM home directory: %v\n", err)
+			return
+		}
+
+		plain, err := keyring.DecryptFile(path)
+		if err != nil {
+			fmt.Printf("Error decrypting secrets: %v\n", err)
+			return
+		}
+
+		if secretsDecryptInPlace {
+			if err := os.WriteFile(path, plain, 0600); err != nil {
+				fmt.Printf("Error writing %s: %v\n", path, err)
+				return
+			}
+			fmt.Printf("Decrypted %s. Run 'cpm secrets encrypt' before committing it.\n", path)
+			return
+		}
+		fmt.Print(string(plain))
+	},
+}
+
+var secretsEditCmd = &cobra.Command{
+	Use:   "edit [file]",
+	Short: "Edit a secrets file in $EDITOR and re-encrypt it",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := secretsFileArg(args)
+
+		keyring, err := loadKeyring()
+		if err != nil {
+			fmt.Printf("Error getting CPM home directory: %v\n", err)
+			return
+		}
+
+		var plain []byte
+		if _, err := os.Stat(path); err == nil {
+			plain, err = keyring.DecryptFile(path)
+			if err != nil {
+				fmt.Printf("Error decrypting secrets: %v\n", err)
+				return
+			}
+		}
+
+		// The plaintext only lives in a private temp dir while the editor runs
+		tempDir, err := os.MkdirTemp("", "cpm-secrets-*")
+		if err != nil {
+			fmt.Printf("Error creating temp dir: %v\n", err)
+			return
+		}
+		defer os.RemoveAll(tempDir)
+
+		tempFile := filepath.Join(tempDir, secrets.FileName)
+		if err := os.WriteFile(tempFile, plain, 0600); err != nil {
+			fmt.Printf("Error writing temp file: %v\n", err)
+			return
+		}
+
+		editor := exec.Command(editorCommand(), tempFile)
+		editor.Stdin, editor.Stdout, editor.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := editor.Run(); err != nil {
+			fmt.Printf("Error running editor: %v\n", err)
+			return
+		}
+
+		edited, err := os.ReadFile(tempFile)
+		if err != nil {
+			fmt.Printf("Error reading edited file: %v\n", err)
+			return
+		}
+		if bytes.Equal(edited, plain) {
+			fmt.Println("No changes made.")
+			return
+		}
+
+		count, generated, err := keyring.EncryptFile(tempFile)
+		if generated {
+			fmt.Printf("Generated new secrets key at %s\n", keyring.KeyPath())
+		}
+		if err != nil {
+			fmt.Printf("Error encrypting secrets: %v\n", err)
+			return
+		}
+
+		encrypted, err := os.ReadFile(tempFile)
+		if err != nil {
+			fmt.Printf("Error reading encrypted file: %v\n", err)
+			return
+		}
+		if err := os.WriteFile(path, encrypted, 0600); err != nil {
+			fmt.Printf("Error writing %s: %v\n", path, err)
+			return
+		}
+
+		fmt.Printf("Encrypted %d value(s) in %s\n", count, path)
+	},
+}
+
+func secretsFileArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return secrets.FileName
+}
+
+func loadKeyring() (*secrets.Keyring, error) {
+	cpmHome, err := GetCPMHome()
+	if err != nil {
+		return nil, err
+	}
+	return secrets.NewKeyring(cpmHome), nil
+}
+
+func editorCommand() string {
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
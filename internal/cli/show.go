@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/colonyos/cpm/internal/infra/storage"
+	"github.com/colonyos/cpm/internal/usecase"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	showEnv  string
+	showEnvs bool
+)
+
+func init() {
+	showValuesCmd.Flags().StringVar(&showEnv, "env", "", "Show values with the values-<env>.yaml profile merged in")
+	showValuesCmd.Flags().BoolVar(&showEnvs, "envs", false, "List the environment profiles defined by the package")
+
+	showCmd.AddCommand(showValuesCmd)
+	rootCmd.AddCommand(showCmd)
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show information about a package",
+}
+
+var showValuesCmd = &cobra.Command{
+	Use:   "values [path]",
+	Short: "Show the default values of a package",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		pkgService := storage.NewFsPackageService()
+		uc := usecase.NewShowValuesUseCase(pkgService)
+
+		if showEnvs {
+			envs, err := uc.Environments(path)
+			if err != nil {
+				fmt.Printf("Error listing environments: %v\n", err)
+				return
+			}
+			if len(envs) == 0 {
+				fmt.Println("No environment profiles found.")
+				return
+			}
+			for _, env := range envs {
+				fmt.Println(env)
+			}
+			return
+		}
+
+		values, err := uc.Execute(cmd.Context(), path, showEnv)
+		if err != nil {
+			fmt.Printf("Error showing values: %v\n", err)
+			return
+		}
+
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(values); err != nil {
+			fmt.Printf("Error encoding values: %v\n", err)
+		}
+	},
+}
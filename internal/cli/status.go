@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/colonyos/cpm/internal/infra/storage"
+	"github.com/colonyos/cpm/internal/usecase"
+	"github.com/colonyos/cpm/pkg/domain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusConn   connectionOptions
+	statusOutput string
+)
+
+func init() {
+	statusConn.addFlags(statusCmd.Flags())
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "table", "Output format: table or json")
+
+	rootCmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status [release]",
+	Short: "Show the live state of a release's resources in the colony",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if statusOutput != "table" && statusOutput != "json" {
+			fmt.Printf("Error: unknown output format %q (use table or json)\n", statusOutput)
+			return
+		}
+
+		cpmHome, err := GetCPMHome()
+		if err != nil {
+			fmt.Printf("Error getting CPM home directory: %v\n", err)
+			return
+		}
+
+		cfg, err := LoadConfig(cpmHome)
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+
+		if err := statusConn.resolve(cmd.Flags(), cfg); err != nil {
+			fmt.Printf("Error resolving connection: %v\n", err)
+			return
+		}
+		if !statusConn.configured() {
+			fmt.Println("Error: --colonyid and --prvkey are required to query the colony")
+			return
+		}
+
+		stateService, err := storage.NewJSONStateService(cpmHome)
+		if err != nil {
+			fmt.Printf("Error initializing state service: %v\n", err)
+			return
+		}
+
+		client, err := statusConn.newColonyClient(cfg)
+		if err != nil {
+			fmt.Printf("Error initializing colony client: %v\n", err)
+			return
+		}
+		reader, ok := client.(domain.StatusReader)
+		if !ok {
+			fmt.Println("Error: the colony client cannot report status")
+			return
+		}
+
+		uc := usecase.NewReleaseStatusUseCase(stateService, reader)
+		status, err := uc.Execute(cmd.Context(), args[0])
+		if err != nil {
+			fmt.Printf("Error getting release status: %v\n", err)
+			return
+		}
+
+		if statusOutput == "json" {
+			out, _ := json.MarshalIndent(status, "", "  ")
+			fmt.Println(string(out))
+			return
+		}
+		printStatus(status)
+	},
+}
+
+// printStatus prints a release and a table of its resources, with the
+// processes of each workflow below it.
+func printStatus(status *usecase.ReleaseStatus) {
+	fmt.Printf("Release %s (version %s, revision %d): %s\n\n", status.Name, status.Version, status.Revision, status.Status)
+	if len(status.Resources) == 0 {
+		fmt.Println("No resources recorded.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tID\tSTATE\tEXECUTOR\tSTARTED\tENDED\tERRORS")
+	for _, r := range status.Resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t-\t-\t-\n", r.Kind, r.Name, shortID(r.ID), r.State, orDash(shortID(r.ExecutorID)))
+		for _, p := range r.Processes {
+			name := p.Name
+			if name == "" {
+				name = shortID(p.ID)
+			}
+			fmt.Fprintf(w, "  process\t%s/%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Name, name, shortID(p.ID), p.State,
+				orDash(shortID(p.ExecutorID)), formatTime(p.StartTime), formatTime(p.EndTime), orDash(strings.Join(p.Errors, "; ")))
+		}
+	}
+	w.Flush()
+}
+
+// shortID shortens a hex colony ID for display; -o json shows it in
+// full. Anything else, such as an executor name, is left as is.
+func shortID(id string) string {
+	if len(id) <= 12 {
+		return id
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return id
+	}
+	return id[:12]
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
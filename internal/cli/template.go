@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/infra/registry"
+	"github.com/colonyos/cpm/internal/infra/storage"
+	"github.com/colonyos/cpm/internal/secrets"
+	"github.com/colonyos/cpm/internal/usecase"
+	"github.com/spf13/cobra"
+)
+
+var (
+	templateValues      valueOptions
+	templateVersion     string
+	templateShowSecrets bool
+	templateDebug       bool
+)
+
+func init() {
+	templateValues.addFlags(templateCmd.Flags())
+	templateCmd.Flags().StringVar(&templateVersion, "version", "", "Package version (required if rendering from registry)")
+	templateCmd.Flags().BoolVar(&templateShowSecrets, "show-secrets", false, "Show decrypted secret values instead of redacting them")
+	templateCmd.Flags().BoolVar(&templateDebug, "debug", false, "Report which templates were rendered or skipped on stderr")
+
+	rootCmd.AddCommand(templateCmd)
+}
+
+var templateCmd = &cobra.Command{
+	Use:   "template [path]",
+	Short: "Render a package locally and print the specs",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+
+		pkgService := storage.NewFsPackageService()
+		renderer := engine.NewGoTemplateEngine()
+		if templateDebug {
+			renderer = renderer.WithDebug(os.Stderr)
+		}
+
+		cpmHome, err := GetCPMHome()
+		if err != nil {
+			fmt.Printf("Error getting CPM home directory: %v\n", err)
+			return
+		}
+
+		cfg, err := LoadConfig(cpmHome)
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+
+		regService, err := registry.NewMockRegistryService(cpmHome)
+		if err != nil {
+			fmt.Printf("Error initializing registry: %v\n", err)
+			return
+		}
+
+		keyring := secrets.NewKeyring(cpmHome)
+
+		uc := usecase.NewTemplatePackageUseCase(pkgService, renderer, regService, keyring)
+
+		renderOpts, err := templateValues.renderOptions(cfg, templateVersion)
+		if err != nil {
+			fmt.Printf("Error parsing options: %v\n", err)
+			return
+		}
+
+		out, err := uc.Execute(cmd.Context(), path, usecase.TemplateOptions{
+			RenderOptions: renderOpts,
+			ShowSecrets:   templateShowSecrets,
+		})
+		if err != nil {
+			fmt.Printf("Error rendering package: %v\n", err)
+			return
+		}
+
+		fmt.Println(string(out))
+	},
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/infra/storage"
+	"github.com/colonyos/cpm/internal/pkgtest"
+	"github.com/colonyos/cpm/internal/usecase"
+	"github.com/spf13/cobra"
+)
+
+var (
+	testJUnitPath       string
+	testSnapshot        bool
+	testUpdateSnapshots bool
+)
+
+func init() {
+	testCmd.Flags().StringVar(&testJUnitPath, "junit", "", "Write a JUnit XML report to this file")
+	testCmd.Flags().BoolVar(&testSnapshot, "snapshot", false, "Compare the rendered output with the snapshots in tests/__snapshots__")
+	testCmd.Flags().BoolVar(&testUpdateSnapshots, "update-snapshots", false, "Overwrite snapshots that differ from the rendered output")
+
+	rootCmd.AddCommand(testCmd)
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test [path]",
+	Short: "Run the unit tests in a package's tests/ directory",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		pkgService := storage.NewFsPackageService()
+		renderer := engine.NewGoTemplateEngine()
+		uc := usecase.NewTestPackageUseCase(pkgService, renderer)
+
+		results, err := uc.Execute(cmd.Context(), path, usecase.TestOptions{
+			Snapshot:        testSnapshot,
+			UpdateSnapshots: testUpdateSnapshots,
+		})
+		if err != nil {
+			fmt.Printf("Error running tests: %v\n", err)
+			os.Exit(1)
+		}
+
+		failed := 0
+		for _, r := range results {
+			if r.Passed() {
+				if r.SnapshotWritten {
+					fmt.Printf("PASS  %s / %s (snapshot written)\n", r.Suite, r.Name)
+				} else {
+					fmt.Printf("PASS  %s / %s\n", r.Suite, r.Name)
+				}
+				continue
+			}
+			failed++
+			fmt.Printf("FAIL  %s / %s\n", r.Suite, r.Name)
+			for _, f := range r.Failures {
+				fmt.Printf("      %s\n", strings.ReplaceAll(f, "\n", "\n      "))
+			}
+		}
+
+		if testJUnitPath != "" {
+			f, err := os.Create(testJUnitPath)
+			if err != nil {
+				fmt.Printf("Error creating JUnit report: %v\n", err)
+				os.Exit(1)
+			}
+			err = pkgtest.WriteJUnit(f, results)
+			f.Close()
+			if err != nil {
+				fmt.Printf("Error writing JUnit report: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("\n%d passed, %d failed\n", len(results)-failed, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
@@ -35,7 +35,7 @@ var uninstallCmd = &cobra.Command{
 		sdk := colony.NewMockSDK()
 
 		uc := usecase.NewUninstallPackageUseCase(stateService, sdk)
-		err = uc.Execute(name)
+		err = uc.Execute(cmd.Context(), name)
 		if err != nil {
 			fmt.Printf("Error uninstalling package: %v\n", err)
 			return
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/infra/postrender"
+	"github.com/colonyos/cpm/internal/usecase"
+	"github.com/spf13/pflag"
+)
+
+// valueOptions holds the flags used to override package values.
+type valueOptions struct {
+	valueFiles   []string
+	values       []string
+	stringValues []string
+	fileValues   []string
+	env          string
+	sandbox      bool
+	allowFuncs   []string
+	patchFiles   []string
+	postRenderer string
+	postArgs     []string
+}
+
+func (o *valueOptions) addFlags(fs *pflag.FlagSet) {
+	fs.StringArrayVarP(&o.valueFiles, "values", "f", []string{}, "Specify values in a YAML file (can specify multiple, later files take precedence)")
+	fs.StringArrayVar(&o.values, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	fs.StringArrayVar(&o.stringValues, "set-string", []string{}, "Set STRING values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	fs.StringArrayVar(&o.fileValues, "set-file", []string{}, "Set values from files on the command line (can specify multiple or separate values with commas: key1=path1,key2=path2)")
+	fs.StringVar(&o.env, "env", "", "Environment profile to apply (merges values-<env>.yaml over values.yaml)")
+	fs.BoolVar(&o.sandbox, "sandbox", false, "Render with the restricted template function set (always on for registry packages)")
+	fs.StringSliceVar(&o.allowFuncs, "allow-functions", []string{}, "Approve restricted template functions declared by the package (e.g. env,now)")
+	fs.StringArrayVar(&o.patchFiles, "patch", []string{}, "Apply JSON Patch or merge patches from a file to the rendered specs (can specify multiple)")
+	fs.StringVar(&o.postRenderer, "post-renderer", "", "Path to an executable that transforms the rendered specs (JSON array on stdin, specs on stdout)")
+	fs.StringArrayVar(&o.postArgs, "post-renderer-args", []string{}, "An argument to the post-renderer (can specify multiple)")
+}
+
+// renderOptions builds the use case render options from the flags, with
+// the default environment taken from the config.
+func (o *valueOptions) renderOptions(cfg *Config, version string) (usecase.RenderOptions, error) {
+	overrides, err := o.mergeValues()
+	if err != nil {
+		return usecase.RenderOptions{}, err
+	}
+	opts := usecase.RenderOptions{
+		Version:    version,
+		Env:        o.env,
+		DefaultEnv: cfg.DefaultEnv,
+		Values:     overrides,
+		Sandbox:    o.sandbox,
+
+		AllowedFunctions: o.allowFuncs,
+		Patches:          o.patchFiles,
+	}
+
+	if o.postRenderer != "" {
+		pr, err := postrender.NewExecPostRenderer(o.postRenderer, o.postArgs)
+		if err != nil {
+			return usecase.RenderOptions{}, err
+		}
+		opts.PostRenderer = pr
+	}
+	return opts, nil
+}
+
+// mergeValues combines the values files and --set flags into a single
+// overrides map. Values files are merged in order, then --set, --set-string
+// and --set-file are applied on top.
+func (o *valueOptions) mergeValues() (map[string]interface{}, error) {
+	overrides := make(map[string]interface{})
+
+	for _, path := range o.valueFiles {
+		fileValues, err := engine.ReadValuesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		overrides = engine.MergeValues(overrides, fileValues)
+	}
+
+	for _, s := range o.values {
+		if err := engine.ParseSet(s, overrides); err != nil {
+			return nil, fmt.Errorf("failed parsing --set data: %w", err)
+		}
+	}
+
+	for _, s := range o.stringValues {
+		if err := engine.ParseSetString(s, overrides); err != nil {
+			return nil, fmt.Errorf("failed parsing --set-string data: %w", err)
+		}
+	}
+
+	for _, s := range o.fileValues {
+		if err := engine.ParseSetFile(s, overrides); err != nil {
+			return nil, fmt.Errorf("failed parsing --set-file data: %w", err)
+		}
+	}
+
+	return overrides, nil
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"sync"
+	"text/template"
+)
+
+// renderCache holds what an engine reuses across templates and Render
+// calls: the function map, which only depends on the engine options, and
+// the parsed templates, keyed by a digest of their name and source.
+// Parsed templates are safe to execute concurrently. The cache holds at
+// most maxParsedTemplates templates and is emptied when it is full.
+type renderCache struct {
+	funcMapOnce sync.Once
+	funcMap     template.FuncMap
+
+	mu     sync.Mutex
+	parsed map[[sha256.Size]byte]*template.Template
+}
+
+// maxParsedTemplates bounds the parsed templates an engine keeps, so an
+// engine rendering many different packages does not grow without limit.
+const maxParsedTemplates = 1024
+
+func newRenderCache() *renderCache {
+	return &renderCache{parsed: make(map[[sha256.Size]byte]*template.Template)}
+}
+
+// funcs returns the function map for e, building it on first use.
+func (c *renderCache) funcs(e *GoTemplateEngine) template.FuncMap {
+	c.funcMapOnce.Do(func() {
+		c.funcMap = buildFuncMap(e.sandbox, e.allowed)
+	})
+	return c.funcMap
+}
+
+// parse returns the parsed template for name and source, parsing it on a
+// cache miss. Parse errors are not cached.
+func (c *renderCache) parse(name, source string, funcMap template.FuncMap) (*template.Template, error) {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(source))
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	c.mu.Lock()
+	tmpl, ok := c.parsed[key]
+	c.mu.Unlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(funcMap).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.parsed) >= maxParsedTemplates {
+		clear(c.parsed)
+	}
+	c.parsed[key] = tmpl
+	c.mu.Unlock()
+	return tmpl, nil
+}
+
+// copyValue returns a deep copy of a values tree.
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[k] = copyValue(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, val := range t {
+			l[i] = copyValue(val)
+		}
+		return l
+	}
+	return v
+}
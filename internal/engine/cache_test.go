@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+)
+
+// benchmarkPackage returns a package with n templates that use values,
+// functions and control flow, like a package with many workflows.
+func benchmarkPackage(n int) fstest.MapFS {
+	fsys := fstest.MapFS{
+		"colony.yaml": {Data: []byte("name: bench\nversion: 0.1.0\n")},
+	}
+	for i := 0; i < n; i++ {
+		fsys[fmt.Sprintf("templates/workflow-%03d.json", i)] = &fstest.MapFile{Data: []byte(fmt.Sprintf(`{
+    "name": "{{ .Values.name }}-%d",
+    "replicas": {{ .Values.replicas }},
+    "labels": [{{ range $i, $l := .Values.labels }}{{ if $i }}, {{ end }}{{ $l | upper | quote }}{{ end }}],
+    "image": {{ .Values.image | quote }}
+}`, i))}
+	}
+	return fsys
+}
+
+var benchmarkValues = map[string]interface{}{
+	"name":     "bench",
+	"replicas": 3,
+	"labels":   []interface{}{"a", "b", "c"},
+	"image":    "busybox",
+}
+
+// BenchmarkRenderCached renders a package repeatedly with one engine, as
+// cpm test and cpm lint do, so templates are parsed once.
+func BenchmarkRenderCached(b *testing.B) {
+	fsys := benchmarkPackage(300)
+	e := NewGoTemplateEngine()
+	for b.Loop() {
+		if _, err := e.RenderFS(context.Background(), fsys, benchmarkValues); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRenderUncached renders with a new engine each time, so every
+// render builds the function map and parses every template.
+func BenchmarkRenderUncached(b *testing.B) {
+	fsys := benchmarkPackage(300)
+	for b.Loop() {
+		if _, err := NewGoTemplateEngine().RenderFS(context.Background(), fsys, benchmarkValues); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
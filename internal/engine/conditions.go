@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/colonyos/cpm/pkg/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the optional YAML header of a template file:
+//
+//	---
+//	enabled: .Values.metrics.enabled
+//	---
+type frontMatter struct {
+	Enabled string `yaml:"enabled"`
+}
+
+// splitFrontMatter separates the front matter from a template. The front
+// matter lines are replaced by empty lines in the body so line numbers in
+// error messages still match the file.
+func splitFrontMatter(content string) (frontMatter, string, error) {
+	var fm frontMatter
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return fm, content, nil
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return fm, content, fmt.Errorf("front matter is missing its closing ---")
+	}
+
+	if err := yaml.Unmarshal([]byte(strings.Join(lines[1:end], "")), &fm); err != nil {
+		return fm, content, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	body := strings.Repeat("\n", end+1) + strings.Join(lines[end+1:], "")
+	return fm, body, nil
+}
+
+// loadTemplateConditions returns the enabled conditions declared in the
+// templates section of colony.yaml, keyed by slash separated path relative
+// to templates/. Packages without a manifest have no conditions.
+func loadTemplateConditions(fsys fs.FS) (map[string]string, error) {
+	data, err := fs.ReadFile(fsys, "colony.yaml")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifest domain.ColonyManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	conditions := make(map[string]string)
+	for _, t := range manifest.Templates {
+		if t.Enabled != "" {
+			conditions[filepath.ToSlash(t.File)] = t.Enabled
+		}
+	}
+	return conditions, nil
+}
+
+// evalCondition evaluates an enabled expression, e.g.
+// ".Values.metrics.enabled" or "eq .Values.env \"prod\"", with the same
+// truthiness as {{ if }}. A condition that refers to a missing key, e.g.
+// .Values.metrics.enabled without a metrics key, is false.
+func evalCondition(expr string, funcMap template.FuncMap, data interface{}) (bool, error) {
+	tmpl, err := template.New("enabled").Funcs(funcMap).Option("missingkey=error").Parse("{{ if " + expr + " }}true{{ end }}")
+	if err != nil {
+		return false, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		if missingKey(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return buf.String() == "true", nil
+}
+
+// missingKey reports whether a template execution error is caused by a
+// key that is not in the values, or a field of a key set to null.
+func missingKey(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "map has no entry for key") || strings.Contains(msg, "nil pointer evaluating")
+}
@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TemplateError is a render error mapped back to the template source.
+type TemplateError struct {
+	// File is the template path relative to the package, e.g. templates/workflow.json
+	File string
+	// Line and Column are 1-based. Column is 0 if unknown.
+	Line   int
+	Column int
+	// ValuesPath is the .Values key that was missing or rejected, if known
+	ValuesPath string
+	// Snippet is the offending source line with a caret under the column
+	Snippet string
+	// Rendered is the offending line of the rendered output, for errors
+	// found after rendering
+	Rendered string
+	Message  string
+}
+
+func (e *TemplateError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&sb, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&sb, ":%d", e.Column)
+		}
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Message)
+	if e.ValuesPath != "" {
+		fmt.Fprintf(&sb, " (value: %s)", e.ValuesPath)
+	}
+	if e.Snippet != "" {
+		sb.WriteString("\n")
+		sb.WriteString(e.Snippet)
+	}
+	if e.Rendered != "" {
+		sb.WriteString("\nrendered as:\n")
+		sb.WriteString(e.Rendered)
+	}
+	return sb.String()
+}
+
+var (
+	// template: NAME:LINE[:COL]: REST
+	templateErrorPattern = regexp.MustCompile(`^template: (.+?):(\d+)(?::(\d+))?: (.*)$`)
+	// executing "NAME" at <EXPR>: MSG
+	execErrorPattern  = regexp.MustCompile(`^executing ".*?" at <(.*)>: (.*)$`)
+	missingKeyPattern = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+	valuesPathPattern = regexp.MustCompile(`\.Values(?:\.[A-Za-z0-9_-]+)*`)
+)
+
+// newTemplateError maps a text/template parse or exec error to the
+// template file and source. Errors in another format are wrapped as is.
+func newTemplateError(file string, source string, err error) error {
+	m := templateErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	line, _ := strconv.Atoi(m[2])
+	col := -1
+	if m[3] != "" {
+		col, _ = strconv.Atoi(m[3])
+	}
+
+	te := &TemplateError{
+		File:    file,
+		Line:    line,
+		Column:  col + 1,
+		Message: m[4],
+	}
+
+	if exec := execErrorPattern.FindStringSubmatch(m[4]); exec != nil {
+		expr, msg := exec[1], exec[2]
+		te.Message = strings.TrimPrefix(msg, "error calling required: ")
+		te.ValuesPath = valuesPath(expr, msg)
+	}
+
+	te.Snippet = snippet(source, line, col)
+	return te
+}
+
+// valuesPath extracts the .Values path an exec error refers to. For a
+// missing key the path is cut after that key, since that is the first
+// part that does not exist.
+func valuesPath(expr, msg string) string {
+	path := valuesPathPattern.FindString(expr)
+	if path == "" {
+		return ""
+	}
+	if missing := missingKeyPattern.FindStringSubmatch(msg); missing != nil {
+		parts := strings.Split(path, ".")
+		for i, p := range parts {
+			if p == missing[1] {
+				return strings.Join(parts[:i+1], ".")
+			}
+		}
+		return path + "." + missing[1]
+	}
+	return path
+}
+
+// snippet returns the 1-based line of source prefixed with its number and,
+// if col is known (0-based), a caret line beneath it.
+func snippet(source string, line, col int) string {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := strings.TrimRight(lines[line-1], "\r")
+	prefix := fmt.Sprintf("  %d | ", line)
+	s := prefix + text
+	if col >= 0 && col <= len(text) {
+		// Keep tabs so the caret lines up with the source
+		pad := strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return '\t'
+			}
+			return ' '
+		}, text[:col])
+		s += "\n" + strings.Repeat(" ", len(prefix)-2) + "| " + pad + "^"
+	}
+	return s
+}
+
+// renderedTemplate is the output of one template and its position in the
+// combined JSON array.
+type renderedTemplate struct {
+	file   string
+	source string
+	output string
+	offset int
+}
+
+// checkJSON validates the combined output and maps a syntax error back to
+// the template that produced it.
+func checkJSON(result []byte, parts []renderedTemplate) error {
+	var v interface{}
+	err := json.Unmarshal(result, &v)
+	if err == nil {
+		return nil
+	}
+
+	syntaxErr, ok := err.(*json.SyntaxError)
+	if !ok || len(parts) == 0 {
+		return fmt.Errorf("rendered output is not valid JSON: %w", err)
+	}
+
+	// Offset is the number of bytes read when the error was detected, so
+	// the offending byte is the one before it. Errors on the separator
+	// after a template, or past the end, belong to the template before it.
+	pos := int(syntaxErr.Offset) - 1
+	part := parts[0]
+	for _, p := range parts {
+		if pos >= p.offset {
+			part = p
+		}
+	}
+	local := pos - part.offset
+	if local < 0 {
+		local = 0
+	}
+	if local > len(part.output) {
+		local = len(part.output)
+	}
+
+	return jsonTemplateError(part, local, "invalid JSON: "+syntaxErr.Error())
+}
+
+// checkTemplateJSON validates the output of a single JSON template.
+func checkTemplateJSON(part renderedTemplate) error {
+	var v interface{}
+	err := json.Unmarshal([]byte(part.output), &v)
+	if err == nil {
+		return nil
+	}
+
+	syntaxErr, ok := err.(*json.SyntaxError)
+	if !ok {
+		return &TemplateError{File: part.file, Message: "invalid JSON: " + err.Error()}
+	}
+	local := int(syntaxErr.Offset) - 1
+	if local < 0 {
+		local = 0
+	}
+	return jsonTemplateError(part, local, "invalid JSON: "+syntaxErr.Error())
+}
+
+// jsonTemplateError builds the error for a JSON syntax error at byte offset
+// local of a template's output. The rendered line is mapped to the template
+// line when rendering did not change the number of lines, which holds
+// unless values or helpers expand to multiple lines.
+func jsonTemplateError(part renderedTemplate, local int, msg string) *TemplateError {
+	before := part.output[:local]
+	line := 1 + strings.Count(before, "\n")
+	col := local - (strings.LastIndex(before, "\n") + 1)
+
+	te := &TemplateError{
+		File:     part.file,
+		Message:  msg,
+		Rendered: snippet(part.output, line, col),
+	}
+
+	if strings.Count(part.source, "\n") == strings.Count(part.output, "\n") {
+		te.Line = line
+		te.Snippet = snippet(part.source, line, -1)
+	} else {
+		te.Message = fmt.Sprintf("%s (at rendered line %d)", msg, line)
+	}
+	return te
+}
+
+// specFiles returns the template file of each element of the combined
+// output, found from the offset at which the element starts.
+func specFiles(result []byte, parts []renderedTemplate) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(result))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for dec.More() {
+		// InputOffset is the end of the previous token, so skip the
+		// separator and whitespace to the start of the element
+		pos := int(dec.InputOffset())
+		for pos < len(result) && (result[pos] == ',' || isJSONSpace(result[pos])) {
+			pos++
+		}
+		file := ""
+		for _, p := range parts {
+			if pos >= p.offset {
+				file = p.file
+			}
+		}
+		files = append(files, file)
+
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
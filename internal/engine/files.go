@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Files gives templates access to the non-template files of a package
+// through .Files. Keys are slash separated paths relative to the package
+// root, so lookups behave the same for directories and unpacked archives.
+type Files map[string][]byte
+
+// loadFiles reads every file in the package except those under templates/.
+func loadFiles(fsys fs.FS) (Files, error) {
+	files := make(Files)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p == "templates" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files[p] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Get returns the contents of a file as a string, or "" if it does not exist.
+func (f Files) Get(name string) string {
+	return string(f.GetBytes(name))
+}
+
+// GetBytes returns the contents of a file, or nil if it does not exist.
+func (f Files) GetBytes(name string) []byte {
+	return f[path.Clean(filepath.ToSlash(name))]
+}
+
+// Lines returns the lines of a file, without line endings.
+func (f Files) Lines(name string) []string {
+	s := f.Get(name)
+	if s == "" {
+		return []string{}
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Glob returns the files whose path matches pattern, e.g. "scripts/*.py".
+// "**" matches across directories.
+func (f Files) Glob(pattern string) Files {
+	matched := make(Files)
+	for name, data := range f {
+		if globMatch(pattern, name) {
+			matched[name] = data
+		}
+	}
+	return matched
+}
+
+// AsConfig returns the files as a JSON object of base name to contents,
+// ready to embed in a spec, e.g. as env or a config map.
+func (f Files) AsConfig() (string, error) {
+	return f.asObject(func(data []byte) string { return string(data) })
+}
+
+// AsBase64 returns the files as a JSON object of base name to base64
+// encoded contents.
+func (f Files) AsBase64() (string, error) {
+	return f.asObject(func(data []byte) string { return base64.StdEncoding.EncodeToString(data) })
+}
+
+func (f Files) asObject(encode func([]byte) string) (string, error) {
+	obj := make(map[string]string, len(f))
+	for _, name := range f.names() {
+		obj[path.Base(name)] = encode(f[name])
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (f Files) names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// globMatch matches name against a path.Match pattern where "**" also
+// matches any number of directories.
+func globMatch(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+	var match func(p, n []string) bool
+	match = func(p, n []string) bool {
+		if len(p) == 0 {
+			return len(n) == 0
+		}
+		if p[0] == "**" {
+			for i := 0; i <= len(n); i++ {
+				if match(p[1:], n[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(n) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(p[0], n[0]); !ok {
+			return false
+		}
+		return match(p[1:], n[1:])
+	}
+	return match(patternParts, nameParts)
+}
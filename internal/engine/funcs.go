@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/colonyos/cpm/pkg/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// restrictedFunctions are removed in sandbox mode. They read the
+// installer's environment or network, or are non-deterministic, so an
+// untrusted package could use them to leak data into the rendered specs.
+var restrictedFunctions = []string{
+	// Environment
+	"env",
+	"expandenv",
+
+	// Network
+	"getHostByName",
+
+	// Time
+	"ago",
+	"date",
+	"date_in_zone",
+	"date_modify",
+	"dateInZone",
+	"dateModify",
+	"htmlDate",
+	"htmlDateInZone",
+	"mustDateModify",
+	"now",
+
+	// Randomness
+	"randAlpha",
+	"randAlphaNum",
+	"randAscii",
+	"randBytes",
+	"randInt",
+	"randNumeric",
+	"shuffle",
+	"uuidv4",
+
+	// Key and certificate generation
+	"bcrypt",
+	"htpasswd",
+	"genPrivateKey",
+	"genCA",
+	"genCAWithKey",
+	"genSelfSignedCert",
+	"genSelfSignedCertWithKey",
+	"genSignedCert",
+	"genSignedCertWithKey",
+	"encryptAES",
+
+	// Live colony state
+	"lookup",
+}
+
+// RestrictedFunctions returns the names of the template functions that are
+// unavailable in sandbox mode unless explicitly allowed.
+func RestrictedFunctions() []string {
+	names := append([]string(nil), restrictedFunctions...)
+	sort.Strings(names)
+	return names
+}
+
+// IsRestrictedFunction reports whether name is removed in sandbox mode.
+func IsRestrictedFunction(name string) bool {
+	for _, f := range restrictedFunctions {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupKinds maps the kind names accepted by lookup to domain kinds.
+var lookupKinds = map[string]string{
+	"function":  domain.KindFunction,
+	"functions": domain.KindFunction,
+	"executor":  domain.KindExecutor,
+	"executors": domain.KindExecutor,
+	"cron":      domain.KindCron,
+	"crons":     domain.KindCron,
+	"cronjob":   domain.KindCron,
+	"cronjobs":  domain.KindCron,
+}
+
+// lookupFunc returns the lookup template function, which queries l with
+// ctx. Without a backend it always returns an empty map.
+func lookupFunc(ctx context.Context, l domain.ResourceLookup) func(kind, name string) (map[string]interface{}, error) {
+	return func(kind, name string) (map[string]interface{}, error) {
+		k, ok := lookupKinds[strings.ToLower(kind)]
+		if !ok {
+			return nil, fmt.Errorf("lookup: unknown kind %q (use function, executor or cron)", kind)
+		}
+		if l == nil {
+			return map[string]interface{}{}, nil
+		}
+		return l.Lookup(ctx, k, name)
+	}
+}
+
+// buildFuncMap returns sprig plus the CPM helpers. In sandbox mode the
+// restricted functions are removed, except those in allowed. lookup has
+// no backend here; renders bind it to the engine's, see
+// GoTemplateEngine.funcs.
+func buildFuncMap(sandbox bool, allowed map[string]bool) template.FuncMap {
+	funcMap := sprig.TxtFuncMap()
+
+	funcMap["toYaml"] = func(v interface{}) (string, error) {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(data), "\n"), nil
+	}
+
+	funcMap["quoteJson"] = quoteJSON
+	funcMap["toJsonString"] = toJSONString
+
+	funcMap["required"] = func(warn string, val interface{}) (interface{}, error) {
+		if val == nil {
+			return nil, fmt.Errorf("%s", warn)
+		}
+		if s, ok := val.(string); ok && s == "" {
+			return nil, fmt.Errorf("%s", warn)
+		}
+		return val, nil
+	}
+
+	funcMap["lookup"] = lookupFunc(context.Background(), nil)
+
+	if sandbox {
+		for _, name := range restrictedFunctions {
+			if !allowed[name] {
+				delete(funcMap, name)
+			}
+		}
+	}
+
+	return funcMap
+}
+
+// quoteJSON returns v as a JSON string literal, quotes included, e.g.
+// "name": {{ .Values.name | quoteJson }}. Non-string values are formatted
+// with fmt.Sprint and nil becomes "".
+func quoteJSON(v interface{}) (string, error) {
+	s, err := toJSONString(v)
+	if err != nil {
+		return "", err
+	}
+	return `"` + s + `"`, nil
+}
+
+// toJSONString escapes v for use inside an existing JSON string literal,
+// e.g. "image": "repo/{{ .Values.image | toJsonString }}:latest".
+func toJSONString(v interface{}) (string, error) {
+	s := ""
+	if v != nil {
+		s = fmt.Sprint(v)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	out := strings.TrimSuffix(buf.String(), "\n")
+	return out[1 : len(out)-1], nil
+}
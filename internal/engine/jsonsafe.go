@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"strings"
+)
+
+// IsJSONTemplate reports whether a template file must render to JSON on
+// its own, which is the case for .json and .json.tpl files.
+func IsJSONTemplate(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.tpl")
+}
+
+// Interpolation is a template action that inserts a value into a JSON
+// string literal without escaping it.
+type Interpolation struct {
+	Line   int
+	Action string
+}
+
+// jsonSafeFuncs end a pipeline with output that needs no JSON escaping.
+var jsonSafeFuncs = map[string]bool{
+	"toJsonString": true,
+	"b64enc":       true,
+	"sha1sum":      true,
+	"sha256sum":    true,
+	"adler32sum":   true,
+	"int":          true,
+	"int64":        true,
+	"float64":      true,
+	"len":          true,
+	"add":          true,
+	"add1":         true,
+	"sub":          true,
+	"mul":          true,
+	"div":          true,
+	"mod":          true,
+	"max":          true,
+	"min":          true,
+}
+
+// actionKeywords start actions that do not print a value.
+var actionKeywords = []string{"if", "else", "end", "range", "with", "define", "block", "template", "break", "continue", "/*"}
+
+// UnescapedInterpolations scans the source of a JSON template for actions
+// inside string literals, like "{{ .Values.name }}", whose output is not
+// escaped. A value containing a quote or newline would break the JSON.
+func UnescapedInterpolations(source string) []Interpolation {
+	var found []Interpolation
+	inString := false
+	line := 1
+
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == '\n':
+			line++
+		case strings.HasPrefix(source[i:], "{{"):
+			end := strings.Index(source[i+2:], "}}")
+			if end < 0 {
+				return found
+			}
+			action := source[i : i+2+end+2]
+			if inString && printsUnescaped(action) {
+				found = append(found, Interpolation{Line: line, Action: action})
+			}
+			line += strings.Count(action, "\n")
+			i += len(action) - 1
+		case c == '\\' && inString:
+			// Skip the escaped character, e.g. \"
+			i++
+		case c == '"':
+			inString = !inString
+		}
+	}
+	return found
+}
+
+// printsUnescaped reports whether an action prints a value that is not
+// passed through a JSON-safe function last.
+func printsUnescaped(action string) bool {
+	body := strings.TrimSuffix(strings.TrimPrefix(action, "{{"), "}}")
+	body = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(body, "-"), "-"))
+
+	for _, kw := range actionKeywords {
+		if body == kw || strings.HasPrefix(body, kw+" ") || (kw == "/*" && strings.HasPrefix(body, kw)) {
+			return false
+		}
+	}
+	// Variable declarations and assignments print nothing
+	if strings.HasPrefix(body, "$") && (strings.Contains(body, ":=") || strings.Contains(body, " = ")) {
+		return false
+	}
+
+	pipeline := body
+	if i := strings.LastIndex(body, "|"); i >= 0 {
+		pipeline = body[i+1:]
+	}
+	fields := strings.Fields(pipeline)
+	if len(fields) == 0 {
+		return false
+	}
+	return !jsonSafeFuncs[fields[0]]
+}
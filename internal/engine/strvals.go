@@ -0,0 +1,271 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseSet parses a --set style expression ("a.b=1,c[0]=x") into dest.
+// Scalars are typed: true/false become bools, null becomes nil and
+// integers without a leading zero become ints. Everything else is a string.
+func ParseSet(s string, dest map[string]interface{}) error {
+	return newSetParser(s, dest, typedValue).parse()
+}
+
+// ParseSetString parses a --set-string expression into dest. Values are
+// always kept as strings.
+func ParseSetString(s string, dest map[string]interface{}) error {
+	return newSetParser(s, dest, func(rs []rune) (interface{}, error) {
+		return string(rs), nil
+	}).parse()
+}
+
+// ParseSetFile parses a --set-file expression into dest. Each value is a
+// path whose file contents become the value.
+func ParseSetFile(s string, dest map[string]interface{}) error {
+	return newSetParser(s, dest, func(rs []rune) (interface{}, error) {
+		data, err := os.ReadFile(string(rs))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file for --set-file: %w", err)
+		}
+		return string(data), nil
+	}).parse()
+}
+
+// maxIndex caps list indices so a typo like a[99999999]=x cannot allocate
+// a huge slice.
+const maxIndex = 65536
+
+type valueReader func([]rune) (interface{}, error)
+
+type setParser struct {
+	// expr is the whole expression, named in errors
+	expr   string
+	sc     *strings.Reader
+	data   map[string]interface{}
+	reader valueReader
+}
+
+func newSetParser(s string, dest map[string]interface{}, reader valueReader) *setParser {
+	return &setParser{expr: s, sc: strings.NewReader(s), data: dest, reader: reader}
+}
+
+func (p *setParser) parse() error {
+	for {
+		if err := p.key(p.data); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("%q: %w", p.expr, err)
+		}
+	}
+}
+
+// key reads one "path=value" assignment into data. It returns io.EOF once
+// the whole input has been consumed.
+func (p *setParser) key(data map[string]interface{}) error {
+	k, last, err := runesUntil(p.sc, "=[,.")
+	if err != nil {
+		if len(k) == 0 {
+			return err
+		}
+		return fmt.Errorf("key %q has no value", string(k))
+	}
+
+	if len(k) == 0 && last != ',' {
+		if last == '.' {
+			return errors.New("key path cannot contain an empty segment")
+		}
+		return errors.New("key cannot be empty")
+	}
+
+	switch last {
+	case '[':
+		idx, err := p.index()
+		if err != nil {
+			return fmt.Errorf("error parsing index for %q: %w", string(k), err)
+		}
+		list, _ := data[string(k)].([]interface{})
+		list, err = p.listItem(list, idx)
+		data[string(k)] = list
+		return err
+	case ',':
+		return fmt.Errorf("key %q has no value (cannot end with ,)", string(k))
+	case '=':
+		v, err := p.value()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		data[string(k)] = v
+		return err
+	case '.':
+		inner, ok := data[string(k)].(map[string]interface{})
+		if !ok {
+			inner = make(map[string]interface{})
+		}
+		err := p.key(inner)
+		data[string(k)] = inner
+		return err
+	}
+	return fmt.Errorf("parse error at %q", string(k))
+}
+
+// listItem reads what follows "name[idx]" and stores it at list[idx].
+func (p *setParser) listItem(list []interface{}, idx int) ([]interface{}, error) {
+	if idx < 0 {
+		return list, fmt.Errorf("negative index %d", idx)
+	}
+	for len(list) <= idx {
+		list = append(list, nil)
+	}
+
+	_, last, err := runesUntil(p.sc, "=[.")
+	if err != nil {
+		return list, fmt.Errorf("list index %d has no value", idx)
+	}
+
+	switch last {
+	case '=':
+		v, err := p.value()
+		list[idx] = v
+		return list, err
+	case '[':
+		next, err := p.index()
+		if err != nil {
+			return list, fmt.Errorf("error parsing nested index: %w", err)
+		}
+		inner, _ := list[idx].([]interface{})
+		inner, err = p.listItem(inner, next)
+		list[idx] = inner
+		return list, err
+	case '.':
+		inner, ok := list[idx].(map[string]interface{})
+		if !ok {
+			inner = make(map[string]interface{})
+		}
+		err := p.key(inner)
+		list[idx] = inner
+		return list, err
+	}
+	return list, fmt.Errorf("parse error after index %d", idx)
+}
+
+func (p *setParser) index() (int, error) {
+	v, _, err := runesUntil(p.sc, "]")
+	if err != nil {
+		return 0, errors.New("missing closing ]")
+	}
+	idx, err := strconv.Atoi(string(v))
+	if err != nil {
+		return 0, err
+	}
+	if idx > maxIndex {
+		return 0, fmt.Errorf("index %d exceeds the maximum of %d", idx, maxIndex)
+	}
+	return idx, nil
+}
+
+// value reads the right-hand side of an assignment, either a scalar up to
+// the next comma or a {a,b,c} list.
+func (p *setParser) value() (interface{}, error) {
+	r, _, err := p.sc.ReadRune()
+	if err != nil {
+		// "key=" with nothing after it is an empty string.
+		v, rerr := p.reader(nil)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return v, io.EOF
+	}
+	if r == '{' {
+		return p.valueList()
+	}
+	p.sc.UnreadRune()
+
+	rs, _, err := runesUntil(p.sc, ",")
+	v, rerr := p.reader(rs)
+	if rerr != nil {
+		return nil, rerr
+	}
+	return v, err
+}
+
+func (p *setParser) valueList() ([]interface{}, error) {
+	var list []interface{}
+	for {
+		rs, last, err := runesUntil(p.sc, ",}")
+		if err != nil {
+			return list, errors.New("list value is missing closing }")
+		}
+		v, err := p.reader(rs)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, v)
+		if last == '}' {
+			break
+		}
+	}
+
+	// A list must be followed by a comma or the end of input.
+	r, _, err := p.sc.ReadRune()
+	if err != nil {
+		return list, io.EOF
+	}
+	if r != ',' {
+		return list, fmt.Errorf("unexpected %q after list value", r)
+	}
+	return list, nil
+}
+
+// runesUntil reads until one of the stop runes, honouring backslash
+// escapes. It returns the runes read and the stop rune that ended the scan,
+// or io.EOF if the input ran out first.
+func runesUntil(sc *strings.Reader, stop string) ([]rune, rune, error) {
+	var v []rune
+	for {
+		r, _, err := sc.ReadRune()
+		if err != nil {
+			return v, r, io.EOF
+		}
+		if r == '\\' {
+			next, _, err := sc.ReadRune()
+			if err != nil {
+				return v, r, io.EOF
+			}
+			v = append(v, next)
+			continue
+		}
+		if strings.ContainsRune(stop, r) {
+			return v, r, nil
+		}
+		v = append(v, r)
+	}
+}
+
+// typedValue converts a --set scalar the same way Helm does.
+func typedValue(rs []rune) (interface{}, error) {
+	s := string(rs)
+	switch strings.ToLower(s) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	// Leading zeros (e.g. "007") are preserved as strings.
+	if len(s) > 0 && s[0] != '0' {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return int(i), nil
+		}
+	}
+	return s, nil
+}
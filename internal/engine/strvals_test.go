@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSet(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want map[string]interface{}
+		// err is a substring of the expected error
+		err string
+	}{
+		{name: "scalar", expr: "name=app", want: map[string]interface{}{"name": "app"}},
+		{name: "typed scalars", expr: "a=true,b=FALSE,c=0,d=42,e=007,f=1.5",
+			want: map[string]interface{}{"a": true, "b": false, "c": 0, "d": 42, "e": "007", "f": "1.5"}},
+		{name: "nested keys", expr: "a.b.c=1,a.d=x",
+			want: map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}, "d": "x"}}},
+		{name: "empty value", expr: "a=", want: map[string]interface{}{"a": ""}},
+		{name: "null", expr: "a=null", want: map[string]interface{}{"a": nil}},
+		{name: "escaped comma", expr: `a=x\,y,b=z`, want: map[string]interface{}{"a": "x,y", "b": "z"}},
+		{name: "escaped dot in key", expr: `a\.b=1`, want: map[string]interface{}{"a.b": 1}},
+		{name: "escaped equals in key", expr: `a\=b=1`, want: map[string]interface{}{"a=b": 1}},
+		{name: "list value", expr: "a={x,y,3}", want: map[string]interface{}{"a": []interface{}{"x", "y", 3}}},
+		{name: "index", expr: "a[0]=x,a[2]=z",
+			want: map[string]interface{}{"a": []interface{}{"x", nil, "z"}}},
+		{name: "nested index", expr: "a[1][0]=x",
+			want: map[string]interface{}{"a": []interface{}{nil, []interface{}{"x"}}}},
+		{name: "map in list", expr: "a[0].b=1,a[0].c=2",
+			want: map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": 1, "c": 2}}}},
+		{name: "empty key", expr: "=x", err: "key cannot be empty"},
+		{name: "empty path segment", expr: "a..b=x", err: "empty segment"},
+		{name: "leading dot", expr: ".a=x", err: "empty segment"},
+		{name: "key without value", expr: "a", err: `key "a" has no value`},
+		{name: "trailing comma after key", expr: "a,", err: "cannot end with ,"},
+		{name: "negative index", expr: "a[-1]=x", err: "negative index"},
+		{name: "index too large", expr: "a[99999999]=x", err: "exceeds the maximum"},
+		{name: "unclosed index", expr: "a[0=x", err: "missing closing ]"},
+		{name: "unclosed list", expr: "a={x,y", err: "missing closing }"},
+		{name: "text after list", expr: "a={x}y", err: "after list value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]interface{}{}
+			err := ParseSet(tt.expr, got)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseSet(%q) error = %v, want one containing %q", tt.expr, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSet(%q): %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseSet(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSetString(t *testing.T) {
+	got := map[string]interface{}{}
+	if err := ParseSetString("a=true,b=42,c=null", got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"a": "true", "b": "42", "c": "null"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseSetString = %#v, want %#v", got, want)
+	}
+}
+
+func TestSetNullDeletesDefault(t *testing.T) {
+	defaults := map[string]interface{}{
+		"a": 1,
+		"b": map[string]interface{}{"c": 2, "d": 3},
+	}
+	overrides := map[string]interface{}{}
+	if err := ParseSet("a=null,b.c=null,e.f=null", overrides); err != nil {
+		t.Fatal(err)
+	}
+	got := CoalesceValues(defaults, overrides)
+	want := map[string]interface{}{
+		"b": map[string]interface{}{"d": 3},
+		"e": map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("values = %#v, want %#v", got, want)
+	}
+}
@@ -2,76 +2,124 @@ package engine
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
-	"gopkg.in/yaml.v3"
+	"github.com/colonyos/cpm/pkg/domain"
 )
 
-type GoTemplateEngine struct{}
+type GoTemplateEngine struct {
+	// sandbox removes the restricted functions, see RestrictedFunctions
+	sandbox bool
+	allowed map[string]bool
+	// lookup backs the lookup function; nil renders it as an empty map
+	lookup domain.ResourceLookup
+	// debug, if set, receives a line for every template rendered or skipped
+	debug io.Writer
+	// cache is reused by every Render call of this engine. Options that
+	// change the function map give the copy a new cache.
+	cache *renderCache
+}
 
 func NewGoTemplateEngine() *GoTemplateEngine {
-	return &GoTemplateEngine{}
+	return &GoTemplateEngine{cache: newRenderCache()}
+}
+
+// WithLookup returns an engine whose lookup function queries the colony
+// through l, with the context of each render.
+func (e *GoTemplateEngine) WithLookup(l domain.ResourceLookup) *GoTemplateEngine {
+	c := *e
+	c.lookup = l
+	return &c
+}
+
+// WithDebug returns an engine that reports which templates were rendered
+// and which were skipped, and why, to w.
+func (e *GoTemplateEngine) WithDebug(w io.Writer) *GoTemplateEngine {
+	c := *e
+	c.debug = w
+	return &c
 }
 
-// Render reads files from the package directory, and returns a map of filename -> rendered content
-func (e *GoTemplateEngine) Render(packagePath string, values map[string]interface{}) ([]byte, error) {
-	templatesDir := filepath.Join(packagePath, "templates")
+// Sandboxed returns an engine for untrusted packages. Restricted functions
+// are not available, except those listed in allowed.
+func (e *GoTemplateEngine) Sandboxed(allowed []string) domain.TemplateEngine {
+	sandboxed := *e
+	sandboxed.sandbox = true
+	sandboxed.cache = newRenderCache()
+	sandboxed.allowed = make(map[string]bool)
+	for _, name := range allowed {
+		sandboxed.allowed[name] = true
+	}
+	return &sandboxed
+}
 
+// Render renders the package in the directory packagePath, see RenderFS.
+func (e *GoTemplateEngine) Render(ctx context.Context, packagePath string, values map[string]interface{}) ([]byte, error) {
 	// Check if directory exists
-	if _, err := os.Stat(templatesDir); os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(packagePath, "templates")); os.IsNotExist(err) {
 		return nil, fmt.Errorf("templates directory not found in %s", packagePath)
 	}
+	return e.RenderFS(ctx, os.DirFS(packagePath), values)
+}
 
-	// Define helper functions
-	funcMap := sprig.TxtFuncMap()
+// RenderFS renders every template of the package rooted at fsys with the
+// values and returns the specs as a JSON array. The package can be a
+// directory, an archive or an embed.FS. Cancelling ctx aborts the colony
+// queries of lookup.
+func (e *GoTemplateEngine) RenderFS(ctx context.Context, fsys fs.FS, values map[string]interface{}) ([]byte, error) {
+	rendered, _, err := e.RenderFSWithSources(ctx, fsys, values)
+	return rendered, err
+}
 
-	funcMap["toYaml"] = func(v interface{}) (string, error) {
-		data, err := yaml.Marshal(v)
-		if err != nil {
-			return "", err
-		}
-		return strings.TrimSuffix(string(data), "\n"), nil
+// RenderFSWithSources is RenderFS that also returns, for each spec in the
+// array, the template file it was rendered from.
+func (e *GoTemplateEngine) RenderFSWithSources(ctx context.Context, fsys fs.FS, values map[string]interface{}) ([]byte, []string, error) {
+	if _, err := fs.Stat(fsys, "templates"); err != nil {
+		return nil, nil, fmt.Errorf("templates directory not found in package")
 	}
 
-	funcMap["required"] = func(warn string, val interface{}) (interface{}, error) {
-		if val == nil {
-			return nil, fmt.Errorf("%s", warn)
-		}
-		if s, ok := val.(string); ok && s == "" {
-			return nil, fmt.Errorf("%s", warn)
-		}
-		return val, nil
+	files, err := loadFiles(fsys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read package files: %w", err)
+	}
+
+	conditions, err := loadTemplateConditions(fsys)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	var parsedTemplates []string
+	var sources []templateSource
 
-	// Walk through templates directory
-	err := filepath.Walk(templatesDir, func(path string, info fs.FileInfo, err error) error {
+	// Walk through templates directory. Paths in fsys are slash separated and
+	// relative to the package root, so they name the templates in errors.
+	err = fs.WalkDir(fsys, "templates", func(tmplName string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
 
 		// Only process .json, .yaml, or .tpl files
-		if !strings.HasSuffix(path, ".json") && !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".tpl") {
+		if !strings.HasSuffix(tmplName, ".json") && !strings.HasSuffix(tmplName, ".yaml") && !strings.HasSuffix(tmplName, ".tpl") {
 			return nil
 		}
 
-		// Parse the file content as a template
-		tmplName := filepath.Base(path)
-
 		// Read file content manually to handle BOM
-		content, err := os.ReadFile(path)
+		content, err := fs.ReadFile(fsys, tmplName)
 		if err != nil {
-			return fmt.Errorf("failed to read template %s: %w", path, err)
+			return fmt.Errorf("failed to read template %s: %w", tmplName, err)
 		}
 
 		// Strip BOM if present
@@ -79,64 +127,192 @@ func (e *GoTemplateEngine) Render(packagePath string, values map[string]interfac
 		sContent := string(content)
 		sContent = strings.TrimPrefix(sContent, bom)
 
-		tmpl, err := template.New(tmplName).Funcs(funcMap).Option("missingkey=error").Parse(sContent)
+		fm, sContent, err := splitFrontMatter(sContent)
 		if err != nil {
-			return fmt.Errorf("failed to parse template %s: %w", path, err)
-		}
-
-		// Execute the template with values
-		var buf bytes.Buffer
-		data := map[string]interface{}{
-			"Values": values,
+			return fmt.Errorf("failed to parse template %s: %w", tmplName, err)
 		}
 
-		if err := tmpl.Execute(&buf, data); err != nil {
-			return fmt.Errorf("failed to render template %s: %w", path, err)
-		}
-
-		parsedTemplates = append(parsedTemplates, buf.String())
+		sources = append(sources, templateSource{
+			name:       tmplName,
+			source:     sContent,
+			conditions: []string{conditions[strings.TrimPrefix(tmplName, "templates/")], fm.Enabled},
+		})
 		return nil
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("no templates found")
 	}
 
-	// Join all rendered templates.
-	// If they are JSONs, we might want to return them as a JSON array or just concatenated?
-	// ColonyOS expects JSON. If we have multiple JSONs (workflow + executors),
-	// typically we might want to return a list of objects or handle them separately.
-	// For MVP, let's return them as a JSON array wrapped in string.
-	// Actually, usually a package manager submits one main thing or a set of things.
-	// Let's join them with newlines for now, assuming the submitter will parse them or they are separate.
-	// Wait, if we concat JSONs like `{...}{...}` it's valid JSON stream but not valid JSON file.
-	// Let's make it a JSON Array `[{...}, {...}]` if multiple.
+	funcMap := e.funcs(ctx)
 
-	if len(parsedTemplates) == 0 {
-		return nil, fmt.Errorf("no templates found")
+	// Templates are independent, so they are executed concurrently. Results
+	// are collected by index to keep the output in file order.
+	results := make([]templateResult, len(sources))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Each template gets its own copy of the values, since functions
+			// like set and merge modify maps in place
+			data := map[string]interface{}{
+				"Values": copyValue(values),
+				"Files":  files,
+			}
+			results[i] = e.renderTemplate(sources[i], funcMap, data)
+		}()
+	}
+	wg.Wait()
+
+	var parsedTemplates []renderedTemplate
+	for i, r := range results {
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		if r.skipped != "" {
+			e.debugf("skipped   %s (%s)", sources[i].name, r.skipped)
+			continue
+		}
+		e.debugf("rendered  %s", sources[i].name)
+		parsedTemplates = append(parsedTemplates, r.rendered)
+	}
+
+	// Join the rendered templates into a JSON array `[{...}, {...}]`, which
+	// the submitter splits into specs.
+	var result strings.Builder
+	result.WriteString("[")
+	for i := range parsedTemplates {
+		if i > 0 {
+			result.WriteString(",")
+		}
+		parsedTemplates[i].offset = result.Len()
+		result.WriteString(parsedTemplates[i].output)
 	}
+	result.WriteString("]")
 
-	result := "[" + strings.Join(parsedTemplates, ",") + "]"
-	return []byte(result), nil
+	// Validate here, where a syntax error can still be traced to its template
+	if err := checkJSON([]byte(result.String()), parsedTemplates); err != nil {
+		return nil, nil, err
+	}
+	specSources, err := specFiles([]byte(result.String()), parsedTemplates)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(result.String()), specSources, nil
+}
+
+// templateSource is a template file read from the package.
+type templateSource struct {
+	name   string
+	source string
+	// conditions are the manifest and front matter enabled expressions,
+	// empty if not set
+	conditions []string
+}
+
+// templateResult is the outcome of rendering one template. Either err,
+// skipped or rendered is set.
+type templateResult struct {
+	rendered renderedTemplate
+	// skipped is the reason the template was left out
+	skipped string
+	err     error
+}
+
+// funcs returns the function map for a render with ctx. With a lookup
+// backend, lookup is bound to it and ctx, so the render can be cancelled.
+func (e *GoTemplateEngine) funcs(ctx context.Context) template.FuncMap {
+	funcMap := e.cache.funcs(e)
+	if _, ok := funcMap["lookup"]; !ok || e.lookup == nil {
+		return funcMap
+	}
+	bound := maps.Clone(funcMap)
+	bound["lookup"] = lookupFunc(ctx, e.lookup)
+	return bound
 }
 
-// Helper to load values.yaml
-func LoadValues(packagePath string) (map[string]interface{}, error) {
-	valuesPath := filepath.Join(packagePath, "values.yaml")
-	file, err := os.Open(valuesPath)
+// renderTemplate renders src with funcMap, the function map of the render,
+// see funcs.
+func (e *GoTemplateEngine) renderTemplate(src templateSource, funcMap template.FuncMap, data map[string]interface{}) templateResult {
+	// Skip the file if its manifest or front matter condition is false
+	for _, cond := range src.conditions {
+		if cond == "" {
+			continue
+		}
+		enabled, err := evalCondition(cond, funcMap, data)
+		if err != nil {
+			return templateResult{err: fmt.Errorf("failed to evaluate enabled condition of %s: %w", src.name, err)}
+		}
+		if !enabled {
+			return templateResult{skipped: fmt.Sprintf("enabled: %s is false", cond)}
+		}
+	}
+
+	// Cached templates are parsed with the engine's function map, so a
+	// render with a lookup backend executes a copy bound to its own
+	tmpl, err := e.cache.parse(src.name, src.source, e.cache.funcs(e))
 	if err != nil {
-		if os.IsNotExist(err) {
-			return make(map[string]interface{}), nil
+		if name := undefinedFunction(err); e.sandbox && IsRestrictedFunction(name) {
+			return templateResult{err: fmt.Errorf("failed to parse template %s: function %q is not available in sandbox mode (declare it in colony.yaml templateFunctions)", src.name, name)}
 		}
-		return nil, err
+		return templateResult{err: fmt.Errorf("failed to parse template: %w", newTemplateError(src.name, src.source, err))}
+	}
+	if lookup, ok := funcMap["lookup"]; ok && e.lookup != nil {
+		if tmpl, err = tmpl.Clone(); err != nil {
+			return templateResult{err: fmt.Errorf("failed to render template %s: %w", src.name, err)}
+		}
+		tmpl.Funcs(template.FuncMap{"lookup": lookup})
+	}
+
+	// Execute the template with values
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return templateResult{err: fmt.Errorf("failed to render template: %w", newTemplateError(src.name, src.source, err))}
 	}
-	defer file.Close()
 
-	var values map[string]interface{}
-	decoder := yaml.NewDecoder(file)
-	if err := decoder.Decode(&values); err != nil {
-		return nil, err
+	// A template that renders to nothing, e.g. wrapped in {{ if }}, is omitted
+	if strings.TrimSpace(buf.String()) == "" {
+		return templateResult{skipped: "rendered empty"}
 	}
 
-	return values, nil
+	rendered := renderedTemplate{
+		file:   src.name,
+		source: src.source,
+		output: buf.String(),
+	}
+
+	// JSON templates are validated on their own, so an error names the file
+	// and a template cannot emit several objects by accident
+	if IsJSONTemplate(src.name) {
+		if err := checkTemplateJSON(rendered); err != nil {
+			return templateResult{err: err}
+		}
+	}
+	return templateResult{rendered: rendered}
+}
+
+func (e *GoTemplateEngine) debugf(format string, args ...interface{}) {
+	if e.debug != nil {
+		fmt.Fprintf(e.debug, format+"\n", args...)
+	}
+}
+
+var undefinedFunctionPattern = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// undefinedFunction returns the function name from a parse error about an
+// undefined function, or "".
+func undefinedFunction(err error) string {
+	if m := undefinedFunctionPattern.FindStringSubmatch(err.Error()); m != nil {
+		return m[1]
+	}
+	return ""
 }
@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PatchesKey is the key of a values-<env>.yaml profile that holds
+// post-render patches rather than values.
+const PatchesKey = "patches"
+
+// LoadValues loads the values of the package in the directory
+// packagePath, see LoadValuesFS.
+func LoadValues(packagePath string, env string) (map[string]interface{}, error) {
+	return LoadValuesFS(os.DirFS(packagePath), env)
+}
+
+// LoadValuesFS loads values.yaml from the package rooted at fsys. If env is
+// set, the values-<env>.yaml overlay is deep-merged on top of it, without
+// its patches key.
+func LoadValuesFS(fsys fs.FS, env string) (map[string]interface{}, error) {
+	values, err := loadValuesFile(fsys, "values.yaml")
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+
+	if env == "" {
+		return values, nil
+	}
+
+	if !HasEnvironmentFS(fsys, env) {
+		return nil, fmt.Errorf("environment %q not found: %s does not exist", env, EnvValuesFile(env))
+	}
+	overlay, err := loadValuesFile(fsys, EnvValuesFile(env))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", EnvValuesFile(env), err)
+	}
+	delete(overlay, PatchesKey)
+
+	return CoalesceValues(values, overlay), nil
+}
+
+// EnvValuesFile returns the file name of the overlay for an environment.
+func EnvValuesFile(env string) string {
+	return "values-" + env + ".yaml"
+}
+
+// HasEnvironment reports whether the package has a values-<env>.yaml overlay.
+func HasEnvironment(packagePath string, env string) bool {
+	return HasEnvironmentFS(os.DirFS(packagePath), env)
+}
+
+// HasEnvironmentFS is HasEnvironment for a package rooted at fsys.
+func HasEnvironmentFS(fsys fs.FS, env string) bool {
+	_, err := fs.Stat(fsys, EnvValuesFile(env))
+	return err == nil
+}
+
+// ListEnvironments returns the environments the package defines overlays
+// for, sorted by name.
+func ListEnvironments(packagePath string) ([]string, error) {
+	return ListEnvironmentsFS(os.DirFS(packagePath))
+}
+
+// ListEnvironmentsFS is ListEnvironments for a package rooted at fsys.
+func ListEnvironmentsFS(fsys fs.FS) ([]string, error) {
+	matches, err := fs.Glob(fsys, "values-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	var envs []string
+	for _, m := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(m, "values-"), ".yaml")
+		if name != "" {
+			envs = append(envs, name)
+		}
+	}
+	sort.Strings(envs)
+	return envs, nil
+}
+
+func loadValuesFile(fsys fs.FS, name string) (map[string]interface{}, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string]interface{}), nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var values map[string]interface{}
+	decoder := yaml.NewDecoder(file)
+	if err := decoder.Decode(&values); err != nil {
+		if err == io.EOF {
+			// Empty file
+			return make(map[string]interface{}), nil
+		}
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// ReadValuesFile reads a user supplied values file (-f/--values).
+func ReadValuesFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file %s: %w", path, err)
+	}
+	return parseValues(path, data)
+}
+
+// ReadValuesFS reads a values file, such as secrets.yaml, from the package
+// rooted at fsys.
+func ReadValuesFS(fsys fs.FS, name string) (map[string]interface{}, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file %s: %w", name, err)
+	}
+	return parseValues(name, data)
+}
+
+func parseValues(path string, data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse values file %s: %w", path, err)
+	}
+	if values == nil {
+		// An empty file decodes to a nil map
+		values = make(map[string]interface{})
+	}
+	return values, nil
+}
+
+// MergeValues deep-merges src into dst and returns dst. Nested maps are
+// merged key by key, anything else in src replaces the value in dst.
+// Nil values are kept so that CoalesceValues can later use them to delete
+// a default.
+func MergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			dst[k] = MergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// CoalesceValues deep-merges user overrides into the package defaults and
+// returns the result. A nil override deletes the key from the defaults.
+func CoalesceValues(defaults, overrides map[string]interface{}) map[string]interface{} {
+	if defaults == nil {
+		defaults = make(map[string]interface{})
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(defaults, k)
+			continue
+		}
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := defaults[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			defaults[k] = CoalesceValues(dstMap, srcMap)
+			continue
+		}
+		if srcIsMap {
+			// Strip null markers from maps that have no default to delete from
+			defaults[k] = CoalesceValues(make(map[string]interface{}), srcMap)
+			continue
+		}
+		defaults[k] = v
+	}
+	return defaults
+}
@@ -2,12 +2,16 @@ package colony
 
 import (
 	"bytes"
-	"crypto/ed25519"
-	"encoding/hex"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/colonyos/cpm/pkg/domain"
 )
 
 type ColonyClient struct {
@@ -15,7 +19,9 @@ type ColonyClient struct {
 	serverPort int
 	colonyID   string
 	prvKey     string
+	scheme     string
 	httpClient *http.Client
+	retry      RetryPolicy
 }
 
 func NewColonyClient(host string, port int, colonyID, prvKey string) *ColonyClient {
@@ -24,67 +30,267 @@ func NewColonyClient(host string, port int, colonyID, prvKey string) *ColonyClie
 		serverPort: port,
 		colonyID:   colonyID,
 		prvKey:     prvKey,
+		scheme:     "http",
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		retry: DefaultRetryPolicy,
 	}
 }
 
-func (c *ColonyClient) SubmitWorkflow(specJSON []byte) error {
-	url := fmt.Sprintf("http://%s:%d/api/workflows", c.serverHost, c.serverPort)
+// WithRetry returns a client that retries failed requests with p.
+func (c *ColonyClient) WithRetry(p RetryPolicy) *ColonyClient {
+	cc := *c
+	cc.retry = p
+	return &cc
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(specJSON))
+// WithTLS returns a client that connects with t.
+func (c *ColonyClient) WithTLS(t TLSConfig) (*ColonyClient, error) {
+	httpClient, err := t.httpClient()
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	cc := *c
+	cc.scheme = t.scheme()
+	cc.httpClient = httpClient
+	return &cc, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Colony-ID", c.colonyID)
+// SubmitWorkflow submits a workflow spec and returns the ID of the process
+// graph the server created for it.
+func (c *ColonyClient) SubmitWorkflow(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error) {
+	body, err := c.post(ctx, "workflows", specJSON, idempotencyKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit workflow: %w", err)
+	}
 
-	// Sign payload if private key is present
-	if c.prvKey != "" {
-		signature, err := c.sign(specJSON)
-		if err != nil {
-			return fmt.Errorf("failed to sign payload: %w", err)
-		}
-		req.Header.Set("X-Colony-Signature", signature)
+	var result struct {
+		ProcessGraphID string `json:"processGraphId"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse submit response: %w", err)
+	}
+	if result.ProcessGraphID == "" {
+		return "", fmt.Errorf("server did not return a process graph ID: %s", string(body))
 	}
 
-	resp, err := c.httpClient.Do(req)
+	fmt.Println("[ColonyClient] Workflow submitted successfully")
+	return result.ProcessGraphID, nil
+}
+
+// RegisterFunction adds a function spec to the colony and returns the
+// function ID assigned by the server.
+func (c *ColonyClient) RegisterFunction(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error) {
+	body, err := c.post(ctx, "functions", specJSON, idempotencyKey)
 	if err != nil {
-		return fmt.Errorf("failed to submit workflow: %w", err)
+		return "", fmt.Errorf("failed to register function: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("server returned error %d: %s", resp.StatusCode, string(body))
+	var result struct {
+		FunctionID string `json:"functionId"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse register response: %w", err)
 	}
+	if result.FunctionID == "" {
+		return "", fmt.Errorf("server did not return a function ID: %s", string(body))
+	}
+	return result.FunctionID, nil
+}
 
-	fmt.Println("[ColonyClient] Workflow submitted successfully")
+// RemoveWorkflow removes the process graph of a submitted workflow. A
+// process graph that does not exist is already removed.
+func (c *ColonyClient) RemoveWorkflow(ctx context.Context, processGraphID string) error {
+	if err := c.delete(ctx, "workflows", processGraphID); err != nil {
+		return fmt.Errorf("failed to remove workflow %s: %w", processGraphID, err)
+	}
+	return nil
+}
+
+// RemoveFunction removes a registered function. A function that does not
+// exist is already removed.
+func (c *ColonyClient) RemoveFunction(ctx context.Context, functionID string) error {
+	if err := c.delete(ctx, "functions", functionID); err != nil {
+		return fmt.Errorf("failed to remove function %s: %w", functionID, err)
+	}
 	return nil
 }
 
-func (c *ColonyClient) RegisterFunction(specJSON []byte) error {
-	// TODO: Implement real registration
-	fmt.Println("[ColonyClient] Registering function to", c.serverHost)
+// GetProcessGraph fetches the state of a submitted workflow and its
+// processes.
+func (c *ColonyClient) GetProcessGraph(ctx context.Context, processGraphID string) (*domain.ProcessGraph, error) {
+	body, err := c.get(ctx, "processgraphs", processGraphID)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, &domain.NotFoundError{Kind: "process graph", ID: processGraphID}
+		}
+		return nil, fmt.Errorf("failed to get process graph %s: %w", processGraphID, err)
+	}
+
+	var graph domain.ProcessGraph
+	if err := json.Unmarshal(body, &graph); err != nil {
+		return nil, fmt.Errorf("failed to parse process graph: %w", err)
+	}
+	if graph.State == "" {
+		graph.State = domain.GraphState(graph.Processes)
+	}
+	return &graph, nil
+}
+
+// GetFunction fetches a registered function by its function ID.
+func (c *ColonyClient) GetFunction(ctx context.Context, functionID string) (*domain.Function, error) {
+	body, err := c.get(ctx, "functions", functionID)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, &domain.NotFoundError{Kind: "function", ID: functionID}
+		}
+		return nil, fmt.Errorf("failed to get function %s: %w", functionID, err)
+	}
+
+	var function domain.Function
+	if err := json.Unmarshal(body, &function); err != nil {
+		return nil, fmt.Errorf("failed to parse function: %w", err)
+	}
+	return &function, nil
+}
+
+// errNotFound is returned by get for a 404.
+var errNotFound = errors.New("not found")
+
+// get sends a signed GET /api/<collection>?id=<id> and returns the
+// response body.
+func (c *ColonyClient) get(ctx context.Context, collection, id string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s://%s:%d/api/%s?id=%s", c.scheme, c.serverHost, c.serverPort, collection, url.QueryEscape(id))
+	status, body, err := c.retry.do(ctx, c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.sign(req, nil); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if status >= 400 {
+		return nil, fmt.Errorf("server returned error %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// delete sends a signed DELETE /api/<collection>?id=<id>.
+func (c *ColonyClient) delete(ctx context.Context, collection, id string) error {
+	endpoint := fmt.Sprintf("%s://%s:%d/api/%s?id=%s", c.scheme, c.serverHost, c.serverPort, collection, url.QueryEscape(id))
+	status, body, err := c.retry.do(ctx, c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequest("DELETE", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.sign(req, nil); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if status >= 400 && status != http.StatusNotFound {
+		return fmt.Errorf("server returned error %d: %s", status, strings.TrimSpace(string(body)))
+	}
 	return nil
 }
 
-func (c *ColonyClient) sign(msg []byte) (string, error) {
-	// Assuming prvKey is hex encoded 64-byte Ed25519 private key
-	// Note: Ed25519 private key is usually 64 bytes (seed + public key) or 32 bytes (seed).
-	// Go's crypto/ed25519 uses 64 bytes.
+// post sends a signed JSON body to /api/<collection> and returns the
+// response body. A 4xx or 5xx status is an error carrying the body.
+// The idempotency key lets the server recognise a retried request.
+func (c *ColonyClient) post(ctx context.Context, collection string, specJSON []byte, idempotencyKey string) ([]byte, error) {
+	url := fmt.Sprintf("%s://%s:%d/api/%s", c.scheme, c.serverHost, c.serverPort, collection)
+
+	status, body, err := c.retry.do(ctx, c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(specJSON))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		if err := c.sign(req, specJSON); err != nil {
+			return nil, fmt.Errorf("failed to sign payload: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, fmt.Errorf("server returned error %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// lookupPaths maps resource kinds to the API collection they are read from
+var lookupPaths = map[string]string{
+	domain.KindFunction: "functions",
+	domain.KindExecutor: "executors",
+	domain.KindCron:     "cronjobs",
+}
+
+// Lookup fetches a function, executor or cron job by name. A 404 from the
+// server means it does not exist and yields an empty map.
+func (c *ColonyClient) Lookup(ctx context.Context, kind, name string) (map[string]interface{}, error) {
+	collection, ok := lookupPaths[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown lookup kind %q", kind)
+	}
 
-	keyBytes, err := hex.DecodeString(c.prvKey)
+	endpoint := fmt.Sprintf("%s://%s:%d/api/%s?name=%s", c.scheme, c.serverHost, c.serverPort, collection, url.QueryEscape(name))
+	status, body, err := c.retry.do(ctx, c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.sign(req, nil); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("invalid private key hex: %w", err)
+		return nil, fmt.Errorf("failed to look up %s %s: %w", kind, name, err)
 	}
 
-	if len(keyBytes) != ed25519.PrivateKeySize {
-		return "", fmt.Errorf("invalid private key length: got %d, want %d", len(keyBytes), ed25519.PrivateKeySize)
+	if status == http.StatusNotFound {
+		return map[string]interface{}{}, nil
+	}
+	if status >= 400 {
+		return nil, fmt.Errorf("server returned error %d: %s", status, strings.TrimSpace(string(body)))
 	}
 
-	sig := ed25519.Sign(ed25519.PrivateKey(keyBytes), msg)
-	return hex.EncodeToString(sig), nil
+	result := make(map[string]interface{})
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse lookup response: %w", err)
+	}
+	return result, nil
+}
+
+// sign signs req and body with a fresh timestamp and nonce, see
+// SignRequest. Without a private key the request is sent unsigned, with
+// only the colony ID.
+func (c *ColonyClient) sign(req *http.Request, body []byte) error {
+	if c.prvKey == "" {
+		req.Header.Set(HeaderColonyID, c.colonyID)
+		return nil
+	}
+	key, err := parsePrivateKey(c.prvKey)
+	if err != nil {
+		return err
+	}
+	SignRequest(req, body, c.colonyID, key, time.Now(), NewNonce())
+	return nil
 }
@@ -1,6 +1,11 @@
 package colony
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+
+	"github.com/colonyos/cpm/pkg/domain"
+)
 
 type MockSDK struct{}
 
@@ -8,13 +13,38 @@ func NewMockSDK() *MockSDK {
 	return &MockSDK{}
 }
 
-func (s *MockSDK) SubmitWorkflow(specJSON []byte) error {
+func (s *MockSDK) SubmitWorkflow(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error) {
 	fmt.Printf("[MockSDK] Simulating submission to ColonyOS...\n")
 	fmt.Printf("[MockSDK] Payload check: %d bytes\n", len(specJSON))
-	return nil
+	return "mock-processgraph-id", nil
 }
 
-func (s *MockSDK) RegisterFunction(specJSON []byte) error {
+func (s *MockSDK) RegisterFunction(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error) {
 	fmt.Printf("[MockSDK] Simulating function registration...\n")
+	return "mock-function-id", nil
+}
+
+func (s *MockSDK) RemoveWorkflow(ctx context.Context, processGraphID string) error {
+	fmt.Printf("[MockSDK] Simulating removal of process graph %s...\n", processGraphID)
+	return nil
+}
+
+func (s *MockSDK) RemoveFunction(ctx context.Context, functionID string) error {
+	fmt.Printf("[MockSDK] Simulating removal of function %s...\n", functionID)
 	return nil
 }
+
+// GetProcessGraph reports every workflow as successful
+func (s *MockSDK) GetProcessGraph(ctx context.Context, processGraphID string) (*domain.ProcessGraph, error) {
+	return &domain.ProcessGraph{ID: processGraphID, State: domain.ProcessSuccessful}, nil
+}
+
+// GetFunction reports every function as registered
+func (s *MockSDK) GetFunction(ctx context.Context, functionID string) (*domain.Function, error) {
+	return &domain.Function{ID: functionID}, nil
+}
+
+// Lookup finds nothing, since there is no colony behind the mock
+func (s *MockSDK) Lookup(ctx context.Context, kind, name string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
@@ -0,0 +1,122 @@
+package colony
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how requests are retried after network errors and
+// 429 or 5xx responses. Retried submissions carry the same idempotency
+// key, so the colony does not create duplicates.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry. It doubles on every
+	// retry, and half of each delay is random jitter.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff, but not a server's Retry-After
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by new clients.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   10 * time.Second,
+}
+
+// do sends the request built by newReq, retrying as the policy allows. It
+// returns the status code and body of the last attempt, or the last
+// network error. Requests are bound to ctx, and cancelling it stops both
+// the request in flight and any retries.
+func (p RetryPolicy) do(ctx context.Context, client *http.Client, newReq func() (*http.Request, error)) (int, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		var retryAfter time.Duration
+		resp, err := client.Do(req.WithContext(ctx))
+		if ctx.Err() != nil {
+			if err == nil {
+				resp.Body.Close()
+			}
+			return 0, nil, ctx.Err()
+		}
+		if err == nil {
+			body, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr == nil && !retryableStatus(resp.StatusCode) {
+				return resp.StatusCode, body, nil
+			}
+			if readErr != nil {
+				err = readErr
+			} else {
+				err = fmt.Errorf("server returned error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+				retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			}
+			if attempt >= p.MaxRetries {
+				if readErr != nil {
+					return 0, nil, readErr
+				}
+				return resp.StatusCode, body, nil
+			}
+		} else if attempt >= p.MaxRetries {
+			return 0, nil, err
+		}
+
+		delay := p.backoff(attempt)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		fmt.Printf("Request failed (%v), retrying in %s (%d/%d)\n", err, delay.Round(time.Millisecond), attempt+1, p.MaxRetries)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the jittered delay before retry number attempt+1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Half fixed, half random, so concurrent clients spread out
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,483 @@
+package colony
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha3"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// RPC message types, named as in the ColonyOS RPC protocol
+const (
+	MsgSubmitWorkflowSpec = "submitworkflowspecmsg"
+	MsgAddFunction        = "addfunctionmsg"
+	MsgGetProcess         = "getprocessmsg"
+	MsgGetProcessGraph    = "getprocessgraphmsg"
+	MsgGetFunction        = "getfunctionmsg"
+	MsgGetFunctions       = "getfunctionsmsg"
+	MsgGetExecutors       = "getexecutorsmsg"
+	MsgGetCrons           = "getcronsmsg"
+	MsgRemoveProcessGraph = "removeprocessgraphmsg"
+	MsgRemoveFunction     = "removefunctionmsg"
+	MsgProcessGraph       = "processgraphmsg"
+	MsgFunction           = "functionmsg"
+	MsgProcess            = "processmsg"
+	MsgFunctions          = "functionsmsg"
+	MsgExecutors          = "executorsmsg"
+	MsgCrons              = "cronsmsg"
+	MsgError              = "error"
+)
+
+// RPCMsg is the envelope every ColonyOS RPC request and reply is sent in.
+// The payload is the base64 encoded message and the signature is taken
+// over the payload string. Requests also carry the signer's public key to
+// name it, but servers verify against the key registered for the colony.
+type RPCMsg struct {
+	Signature   string `json:"signature"`
+	PublicKey   string `json:"publickey,omitempty"`
+	PayloadType string `json:"payloadtype"`
+	Payload     string `json:"payload"`
+	Error       bool   `json:"error"`
+}
+
+// VerifyRPCMsg checks the signature of a request envelope with the key
+// keys resolves for the colony named in its payload, and that the
+// payload's timestamp is within MaxClockSkew of now. An envelope naming a
+// different public key is rejected. Like VerifyRequest, it does not check
+// the nonce; the caller must reject nonces it has seen before.
+func VerifyRPCMsg(msg RPCMsg, now time.Time, keys KeyResolver) (*SignedRequest, error) {
+	if msg.Signature == "" {
+		return nil, fmt.Errorf("missing signature")
+	}
+	payload, err := base64.StdEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload encoding")
+	}
+	var signed struct {
+		ColonyID  string `json:"colonyid"`
+		Nonce     string `json:"nonce"`
+		Timestamp int64  `json:"timestamp"`
+	}
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if signed.ColonyID == "" || signed.Nonce == "" || signed.Timestamp == 0 {
+		return nil, fmt.Errorf("payload is missing colonyid, nonce or timestamp")
+	}
+
+	pub, err := keys(signed.ColonyID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.PublicKey != "" && msg.PublicKey != hex.EncodeToString(pub) {
+		return nil, fmt.Errorf("public key is not the one registered for colony %s", signed.ColonyID)
+	}
+	sig, err := hex.DecodeString(msg.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding")
+	}
+	if !ed25519.Verify(pub, []byte(msg.Payload), sig) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	// The timestamp is checked once it is known to be signed
+	signedAt := time.Unix(signed.Timestamp, 0)
+	if skew := now.Sub(signedAt); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return nil, fmt.Errorf("message timestamp %s is outside the allowed clock skew of %s", signedAt.UTC().Format(time.RFC3339), MaxClockSkew)
+	}
+	return &SignedRequest{
+		ColonyID:  signed.ColonyID,
+		PublicKey: pub,
+		Nonce:     signed.Nonce,
+		Timestamp: signedAt,
+	}, nil
+}
+
+// RPCError is the payload of an error reply.
+type RPCError struct {
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("server returned error %d: %s", e.Status, e.Message)
+}
+
+// isNotFound reports whether err is an error reply with status 404.
+func isNotFound(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Status == http.StatusNotFound
+}
+
+// RPCClient talks to a colony with ColonyOS style RPC envelopes posted to
+// /api, instead of the CPM specific endpoints used by ColonyClient.
+type RPCClient struct {
+	serverHost string
+	serverPort int
+	colonyID   string
+	prvKey     ed25519.PrivateKey
+	scheme     string
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// NewRPCClient returns an RPC client. prvKey is the hex encoded 64-byte
+// Ed25519 private key that signs every message.
+func NewRPCClient(host string, port int, colonyID, prvKey string) (*RPCClient, error) {
+	key, err := parsePrivateKey(prvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RPCClient{
+		serverHost: host,
+		serverPort: port,
+		colonyID:   colonyID,
+		prvKey:     key,
+		scheme:     "http",
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		retry: DefaultRetryPolicy,
+	}, nil
+}
+
+// WithRetry returns a client that retries failed requests with p.
+func (c *RPCClient) WithRetry(p RetryPolicy) *RPCClient {
+	cc := *c
+	cc.retry = p
+	return &cc
+}
+
+// WithTLS returns a client that connects with t.
+func (c *RPCClient) WithTLS(t TLSConfig) (*RPCClient, error) {
+	httpClient, err := t.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	cc := *c
+	cc.scheme = t.scheme()
+	cc.httpClient = httpClient
+	return &cc, nil
+}
+
+// Identity returns the executor or user ID the colony knows this client
+// by: the hex encoded SHA3-256 digest of its public key.
+func (c *RPCClient) Identity() string {
+	return IdentityOf(c.prvKey.Public().(ed25519.PublicKey))
+}
+
+// IdentityOf derives the colony identity of a public key.
+func IdentityOf(pub ed25519.PublicKey) string {
+	sum := sha3.Sum256(pub)
+	return hex.EncodeToString(sum[:])
+}
+
+// SubmitWorkflow submits a workflow spec and returns the ID of the process
+// graph the colony created for it.
+func (c *RPCClient) SubmitWorkflow(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error) {
+	var spec map[string]interface{}
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		return "", fmt.Errorf("invalid workflow spec: %w", err)
+	}
+
+	var reply struct {
+		ProcessGraph struct {
+			ProcessGraphID string `json:"processgraphid"`
+		} `json:"processgraph"`
+	}
+	msg := map[string]interface{}{
+		"msgtype":        MsgSubmitWorkflowSpec,
+		"colonyid":       c.colonyID,
+		"spec":           spec,
+		"idempotencykey": idempotencyKey,
+	}
+	if err := c.call(ctx, MsgSubmitWorkflowSpec, msg, MsgProcessGraph, &reply); err != nil {
+		return "", fmt.Errorf("failed to submit workflow: %w", err)
+	}
+	if reply.ProcessGraph.ProcessGraphID == "" {
+		return "", fmt.Errorf("server did not return a process graph ID")
+	}
+
+	fmt.Println("[RPCClient] Workflow submitted successfully")
+	return reply.ProcessGraph.ProcessGraphID, nil
+}
+
+// RegisterFunction adds a function spec to the colony. Functions belong to
+// an executor, so the spec's executorId defaults to this client's identity.
+func (c *RPCClient) RegisterFunction(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error) {
+	var function map[string]interface{}
+	if err := json.Unmarshal(specJSON, &function); err != nil {
+		return "", fmt.Errorf("invalid function spec: %w", err)
+	}
+	if _, ok := function["executorId"]; !ok {
+		function["executorId"] = c.Identity()
+	}
+
+	var reply struct {
+		Function struct {
+			FunctionID string `json:"functionId"`
+		} `json:"function"`
+	}
+	msg := map[string]interface{}{
+		"msgtype":        MsgAddFunction,
+		"colonyid":       c.colonyID,
+		"function":       function,
+		"idempotencykey": idempotencyKey,
+	}
+	if err := c.call(ctx, MsgAddFunction, msg, MsgFunction, &reply); err != nil {
+		return "", fmt.Errorf("failed to register function: %w", err)
+	}
+	if reply.Function.FunctionID == "" {
+		return "", fmt.Errorf("server did not return a function ID")
+	}
+	return reply.Function.FunctionID, nil
+}
+
+// RemoveWorkflow removes the process graph of a submitted workflow. A
+// process graph that does not exist is already removed.
+func (c *RPCClient) RemoveWorkflow(ctx context.Context, processGraphID string) error {
+	msg := map[string]interface{}{
+		"msgtype":        MsgRemoveProcessGraph,
+		"colonyid":       c.colonyID,
+		"processgraphid": processGraphID,
+	}
+	var reply struct{}
+	if err := c.call(ctx, MsgRemoveProcessGraph, msg, MsgRemoveProcessGraph, &reply); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to remove workflow %s: %w", processGraphID, err)
+	}
+	return nil
+}
+
+// RemoveFunction removes a registered function. A function that does not
+// exist is already removed.
+func (c *RPCClient) RemoveFunction(ctx context.Context, functionID string) error {
+	msg := map[string]interface{}{
+		"msgtype":    MsgRemoveFunction,
+		"colonyid":   c.colonyID,
+		"functionid": functionID,
+	}
+	var reply struct{}
+	if err := c.call(ctx, MsgRemoveFunction, msg, MsgRemoveFunction, &reply); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to remove function %s: %w", functionID, err)
+	}
+	return nil
+}
+
+// GetProcess returns the process with the given ID.
+func (c *RPCClient) GetProcess(ctx context.Context, processID string) (map[string]interface{}, error) {
+	var reply struct {
+		Process map[string]interface{} `json:"process"`
+	}
+	msg := map[string]interface{}{
+		"msgtype":   MsgGetProcess,
+		"colonyid":  c.colonyID,
+		"processid": processID,
+	}
+	if err := c.call(ctx, MsgGetProcess, msg, MsgProcess, &reply); err != nil {
+		return nil, fmt.Errorf("failed to get process %s: %w", processID, err)
+	}
+	return reply.Process, nil
+}
+
+// rpcProcess is a process as sent in ColonyOS RPC messages.
+type rpcProcess struct {
+	ProcessID          string    `json:"processid"`
+	State              string    `json:"state"`
+	AssignedExecutorID string    `json:"assignedexecutorid"`
+	StartTime          time.Time `json:"starttime"`
+	EndTime            time.Time `json:"endtime"`
+	Errors             []string  `json:"errors"`
+	Spec               struct {
+		FuncName string `json:"funcname"`
+		NodeName string `json:"nodename"`
+	} `json:"spec"`
+}
+
+// GetProcessGraph fetches a submitted workflow and then each of its
+// processes.
+func (c *RPCClient) GetProcessGraph(ctx context.Context, processGraphID string) (*domain.ProcessGraph, error) {
+	var reply struct {
+		ProcessGraph struct {
+			ProcessGraphID string   `json:"processgraphid"`
+			State          string   `json:"state"`
+			ProcessIDs     []string `json:"processids"`
+		} `json:"processgraph"`
+	}
+	msg := map[string]interface{}{
+		"msgtype":        MsgGetProcessGraph,
+		"colonyid":       c.colonyID,
+		"processgraphid": processGraphID,
+	}
+	if err := c.call(ctx, MsgGetProcessGraph, msg, MsgProcessGraph, &reply); err != nil {
+		if isNotFound(err) {
+			return nil, &domain.NotFoundError{Kind: "process graph", ID: processGraphID}
+		}
+		return nil, fmt.Errorf("failed to get process graph %s: %w", processGraphID, err)
+	}
+
+	graph := &domain.ProcessGraph{
+		ID:    reply.ProcessGraph.ProcessGraphID,
+		State: reply.ProcessGraph.State,
+	}
+	for _, id := range reply.ProcessGraph.ProcessIDs {
+		var p struct {
+			Process rpcProcess `json:"process"`
+		}
+		msg := map[string]interface{}{
+			"msgtype":   MsgGetProcess,
+			"colonyid":  c.colonyID,
+			"processid": id,
+		}
+		if err := c.call(ctx, MsgGetProcess, msg, MsgProcess, &p); err != nil {
+			return nil, fmt.Errorf("failed to get process %s: %w", id, err)
+		}
+		name := p.Process.Spec.FuncName
+		if name == "" {
+			name = p.Process.Spec.NodeName
+		}
+		graph.Processes = append(graph.Processes, domain.Process{
+			ID:         p.Process.ProcessID,
+			Name:       name,
+			State:      p.Process.State,
+			ExecutorID: p.Process.AssignedExecutorID,
+			StartTime:  p.Process.StartTime,
+			EndTime:    p.Process.EndTime,
+			Errors:     p.Process.Errors,
+		})
+	}
+	if graph.State == "" {
+		graph.State = domain.GraphState(graph.Processes)
+	}
+	return graph, nil
+}
+
+// GetFunction fetches a registered function by its function ID.
+func (c *RPCClient) GetFunction(ctx context.Context, functionID string) (*domain.Function, error) {
+	var reply struct {
+		Function domain.Function `json:"function"`
+	}
+	msg := map[string]interface{}{
+		"msgtype":    MsgGetFunction,
+		"colonyid":   c.colonyID,
+		"functionid": functionID,
+	}
+	if err := c.call(ctx, MsgGetFunction, msg, MsgFunction, &reply); err != nil {
+		if isNotFound(err) {
+			return nil, &domain.NotFoundError{Kind: "function", ID: functionID}
+		}
+		return nil, fmt.Errorf("failed to get function %s: %w", functionID, err)
+	}
+	return &reply.Function, nil
+}
+
+// rpcLookups maps resource kinds to the message that lists them and the
+// reply it is answered with, whose payload holds the list under the key.
+var rpcLookups = map[string]struct{ msg, reply, key string }{
+	domain.KindFunction: {MsgGetFunctions, MsgFunctions, "functions"},
+	domain.KindExecutor: {MsgGetExecutors, MsgExecutors, "executors"},
+	domain.KindCron:     {MsgGetCrons, MsgCrons, "crons"},
+}
+
+// Lookup fetches a function, executor or cron job by name. The colony has
+// no lookup by name, so the objects of the kind are listed and filtered.
+// Finding none yields an empty map.
+func (c *RPCClient) Lookup(ctx context.Context, kind, name string) (map[string]interface{}, error) {
+	l, ok := rpcLookups[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown lookup kind %q", kind)
+	}
+
+	var reply map[string][]map[string]interface{}
+	msg := map[string]interface{}{
+		"msgtype":  l.msg,
+		"colonyid": c.colonyID,
+	}
+	if err := c.call(ctx, l.msg, msg, l.reply, &reply); err != nil {
+		return nil, fmt.Errorf("failed to look up %s %s: %w", kind, name, err)
+	}
+	for _, obj := range reply[l.key] {
+		for _, key := range []string{"name", "funcName", "executorName"} {
+			if obj[key] == name {
+				return obj, nil
+			}
+		}
+	}
+	return map[string]interface{}{}, nil
+}
+
+// sign returns the envelope for msg, with the timestamp and nonce added to
+// the payload so the signature covers them.
+func (c *RPCClient) sign(msgType string, msg map[string]interface{}, now time.Time, nonce string) ([]byte, error) {
+	signed := maps.Clone(msg)
+	signed["timestamp"] = now.Unix()
+	signed["nonce"] = nonce
+	data, err := json.Marshal(signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", msgType, err)
+	}
+	payload := base64.StdEncoding.EncodeToString(data)
+	return json.Marshal(RPCMsg{
+		Signature:   hex.EncodeToString(ed25519.Sign(c.prvKey, []byte(payload))),
+		PublicKey:   hex.EncodeToString(c.prvKey.Public().(ed25519.PublicKey)),
+		PayloadType: msgType,
+		Payload:     payload,
+	})
+}
+
+// call sends msg in a signed envelope and decodes the reply payload, which
+// must be of type want, into out. Every attempt is signed again with a new
+// timestamp and nonce, since servers reject a nonce they have seen before.
+func (c *RPCClient) call(ctx context.Context, msgType string, msg map[string]interface{}, want string, out interface{}) error {
+	url := fmt.Sprintf("%s://%s:%d/api", c.scheme, c.serverHost, c.serverPort)
+	status, body, err := c.retry.do(ctx, c.httpClient, func() (*http.Request, error) {
+		envelope, err := c.sign(msgType, msg, time.Now(), NewNonce())
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("POST", url, bytes.NewReader(envelope))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var reply RPCMsg
+	if err := json.Unmarshal(body, &reply); err != nil {
+		if status >= 400 {
+			return fmt.Errorf("server returned error %d: %s", status, string(body))
+		}
+		return fmt.Errorf("failed to parse reply: %w", err)
+	}
+
+	replyData, err := base64.StdEncoding.DecodeString(reply.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode reply payload: %w", err)
+	}
+
+	if reply.Error || reply.PayloadType == MsgError {
+		var rpcErr RPCError
+		if err := json.Unmarshal(replyData, &rpcErr); err != nil || rpcErr.Message == "" {
+			return fmt.Errorf("server returned error %d: %s", status, string(replyData))
+		}
+		return &rpcErr
+	}
+	if reply.PayloadType != want {
+		return fmt.Errorf("unexpected reply %s, want %s", reply.PayloadType, want)
+	}
+	return json.Unmarshal(replyData, out)
+}
@@ -1,6 +1,8 @@
 package colony
 
+import "context"
+
 // ColonySDK abstracts the ColonyOS client operations
 type ColonySDK interface {
-	SubmitWorkflow(specJSON []byte) error
+	SubmitWorkflow(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error)
 }
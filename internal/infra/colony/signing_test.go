@@ -0,0 +1,121 @@
+package colony
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signingVectorKey is the key of seed 0x00..0x1f
+const signingVectorKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+	"03a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b8"
+
+// signingVectors are known requests with their expected canonical form
+// and signature. Other implementations of the signing scheme, such as a
+// colony server, can check themselves against them.
+var signingVectors = []struct {
+	name string
+	// privateKey is the hex encoded 64-byte Ed25519 key that signs
+	privateKey string
+	method     string
+	requestURI string
+	timestamp  string
+	nonce      string
+	colonyID   string
+	body       string
+	// canonical is the expected CanonicalRequest
+	canonical string
+	// signature is the expected hex encoded X-Colony-Signature
+	signature string
+}{
+	{
+		name:       "submit workflow",
+		privateKey: signingVectorKey,
+		method:     "POST",
+		requestURI: "/api/workflows",
+		timestamp:  "1767225600",
+		nonce:      "000102030405060708090a0b0c0d0e0f",
+		colonyID:   "colony-1",
+		body:       `{"name":"hello"}`,
+		canonical: "CPM-ED25519-V1\nPOST\n/api/workflows\n1767225600\n000102030405060708090a0b0c0d0e0f\ncolony-1\n" +
+			"26dba3998c572e52bf475c1bb66cef2a1e11b7d1aa8026a983e7ed5bf68bcade",
+		signature: "99d00698b43e61ea89b6113eb4cc6e38381cf3fc9d89d3556c7b61de8e8f5d86" +
+			"94e7bcb7f11542a258fe7cbd63e59f21e952e3c8933160d7f834ab472cde6d06",
+	},
+	{
+		name:       "lookup with empty body",
+		privateKey: signingVectorKey,
+		method:     "GET",
+		requestURI: "/api/functions?name=train",
+		timestamp:  "1767225600",
+		nonce:      "f0e0d0c0b0a090807060504030201000",
+		colonyID:   "colony-1",
+		canonical: "CPM-ED25519-V1\nGET\n/api/functions?name=train\n1767225600\nf0e0d0c0b0a090807060504030201000\ncolony-1\n" +
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		signature: "f142ea29184f868b458635c578a49dca451f95b290e1ffff38c484d6aacd19df" +
+			"c8ebfff848d392cd133ba7493db3a6c7ba092a097658b7985bb0650a36397f03",
+	},
+}
+
+func TestSigningVectors(t *testing.T) {
+	for _, v := range signingVectors {
+		t.Run(v.name, func(t *testing.T) {
+			if got := CanonicalRequest(v.method, v.requestURI, v.timestamp, v.nonce, v.colonyID, []byte(v.body)); got != v.canonical {
+				t.Fatalf("canonical request is %q, want %q", got, v.canonical)
+			}
+
+			key, err := parsePrivateKey(v.privateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(v.method, "http://localhost"+v.requestURI, bytes.NewReader([]byte(v.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			secs, err := strconv.ParseInt(v.timestamp, 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signedAt := time.Unix(secs, 0)
+			SignRequest(req, []byte(v.body), v.colonyID, key, signedAt, v.nonce)
+			if got := req.Header.Get(HeaderSignature); got != v.signature {
+				t.Fatalf("signature is %s, want %s", got, v.signature)
+			}
+
+			registered := func(colonyID string) (ed25519.PublicKey, error) {
+				if colonyID != v.colonyID {
+					return nil, fmt.Errorf("no key for colony %s", colonyID)
+				}
+				return key.Public().(ed25519.PublicKey), nil
+			}
+			if _, err := VerifyRequest(req, []byte(v.body), signedAt, registered); err != nil {
+				t.Fatalf("signature does not verify: %v", err)
+			}
+			// A changed body must not verify
+			if _, err := VerifyRequest(req, append([]byte(v.body), ' '), signedAt, registered); err == nil {
+				t.Fatal("signature verifies a modified body")
+			}
+
+			// A key other than the colony's must not verify, even when the
+			// request names it as its signer
+			other := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+			otherKey := func(string) (ed25519.PublicKey, error) { return other.Public().(ed25519.PublicKey), nil }
+			if _, err := VerifyRequest(req, []byte(v.body), signedAt, otherKey); err == nil {
+				t.Fatal("signature verifies with a key not registered for the colony")
+			}
+			forged := req.Clone(req.Context())
+			SignRequest(forged, []byte(v.body), v.colonyID, other, signedAt, v.nonce)
+			if _, err := VerifyRequest(forged, []byte(v.body), signedAt, registered); err == nil {
+				t.Fatal("request signed by an unregistered key verifies")
+			}
+			forged.Header.Set(HeaderColonyID, "colony-2")
+			if _, err := VerifyRequest(forged, []byte(v.body), signedAt, registered); err == nil {
+				t.Fatal("request for a colony without a key verifies")
+			}
+		})
+	}
+}
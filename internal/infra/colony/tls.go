@@ -0,0 +1,82 @@
+package colony
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TLSConfig configures HTTPS connections to the colony. Setting any of
+// its options enables TLS, so a CA or client certificate is never
+// silently ignored over plain HTTP.
+type TLSConfig struct {
+	Enabled bool
+	// CAFile is a PEM bundle of CAs to trust in addition to the system pool
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables server certificate verification
+	InsecureSkipVerify bool
+}
+
+// enabled reports whether TLS is on, either explicitly or implied by an
+// option.
+func (t TLSConfig) enabled() bool {
+	return t.Enabled || t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" || t.InsecureSkipVerify
+}
+
+// scheme returns the URL scheme for the configuration.
+func (t TLSConfig) scheme() string {
+	if t.enabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// httpClient returns an HTTP client using the configuration.
+func (t TLSConfig) httpClient() (*http.Client, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if !t.enabled() {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("both a client certificate and key are required for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client.Transport = transport
+	return client, nil
+}
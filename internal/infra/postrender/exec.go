@@ -0,0 +1,63 @@
+package postrender
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// waitDelay is how long Run waits for the output of a cancelled
+// post-renderer to close.
+const waitDelay = 2 * time.Second
+
+// ExecPostRenderer pipes the rendered specs through an external executable.
+type ExecPostRenderer struct {
+	path string
+	args []string
+}
+
+// NewExecPostRenderer resolves the executable, either a path or a command
+// on the PATH, and returns a post-renderer that runs it with args.
+func NewExecPostRenderer(command string, args []string) (*ExecPostRenderer, error) {
+	path := command
+	if strings.ContainsRune(command, filepath.Separator) || strings.ContainsRune(command, '/') {
+		abs, err := filepath.Abs(command)
+		if err != nil {
+			return nil, err
+		}
+		path = abs
+	}
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("post-renderer %s not found or not executable: %w", command, err)
+	}
+	return &ExecPostRenderer{path: resolved, args: args}, nil
+}
+
+// Run writes the rendered specs to the executable's stdin and returns its
+// stdout. A non-zero exit status is an error that includes its stderr.
+// Cancelling ctx kills the executable.
+func (p *ExecPostRenderer) Run(ctx context.Context, rendered []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, p.path, p.args...)
+	// Children of a killed executable may keep its output open
+	cmd.WaitDelay = waitDelay
+	cmd.Stdin = bytes.NewReader(rendered)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return nil, fmt.Errorf("post-renderer %s failed: %w\n%s", filepath.Base(p.path), err, msg)
+		}
+		return nil, fmt.Errorf("post-renderer %s failed: %w", filepath.Base(p.path), err)
+	}
+	return stdout.Bytes(), nil
+}
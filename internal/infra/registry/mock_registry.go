@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -21,7 +22,10 @@ func NewMockRegistryService(homeDir string) (*MockRegistryService, error) {
 	return &MockRegistryService{basePath: path}, nil
 }
 
-func (r *MockRegistryService) Publish(artifactPath string) error {
+func (r *MockRegistryService) Publish(ctx context.Context, artifactPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fileName := filepath.Base(artifactPath)
 	destPath := filepath.Join(r.basePath, fileName)
 
@@ -47,7 +51,10 @@ func (r *MockRegistryService) Publish(artifactPath string) error {
 	return nil
 }
 
-func (r *MockRegistryService) Fetch(packageName, version string) (string, error) {
+func (r *MockRegistryService) Fetch(ctx context.Context, packageName, version string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	// Guess filename
 	fileName := fmt.Sprintf("%s-%s.cpm", packageName, version)
 	remotePath := filepath.Join(r.basePath, fileName)
@@ -86,11 +93,14 @@ func (r *MockRegistryService) Fetch(packageName, version string) (string, error)
 		return "", err
 	}
 
-	fmt.Printf("[MockRegistry] Fetched %s from %s\n", fileName, r.basePath)
+	fmt.Fprintf(os.Stderr, "[MockRegistry] Fetched %s from %s\n", fileName, r.basePath)
 	return destPath, nil
 }
 
-func (r *MockRegistryService) Search(query string) ([]string, error) {
+func (r *MockRegistryService) Search(ctx context.Context, query string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	files, err := os.ReadDir(r.basePath)
 	if err != nil {
 		return nil, err
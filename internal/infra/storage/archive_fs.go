@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveFS is a read-only, in-memory fs.FS holding the files of a .cpm
+// archive, so a package can be rendered without unpacking it to disk.
+type ArchiveFS struct {
+	files map[string]*archiveEntry
+}
+
+type archiveEntry struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+	// children are the names of the entries in a directory, sorted
+	children []string
+}
+
+// OpenArchive reads the .cpm archive at path into an ArchiveFS.
+func OpenArchive(path string) (*ArchiveFS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadArchive(f)
+}
+
+// ReadArchive reads a gzipped tar stream, as written by Pack, into an
+// ArchiveFS.
+func ReadArchive(r io.Reader) (*ArchiveFS, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	a := &ArchiveFS{files: map[string]*archiveEntry{
+		".": {name: ".", mode: fs.ModeDir | 0555},
+	}}
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("invalid path %q in archive", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			a.mkdirAll(name, header.ModTime)
+		case tar.TypeReg:
+			var buf bytes.Buffer
+			if _, err := io.Copy(&buf, tr); err != nil {
+				return nil, err
+			}
+			a.mkdirAll(path.Dir(name), header.ModTime)
+			if _, ok := a.files[name]; !ok {
+				a.addChild(name)
+			}
+			a.files[name] = &archiveEntry{
+				name:    path.Base(name),
+				data:    buf.Bytes(),
+				mode:    fs.FileMode(header.Mode).Perm(),
+				modTime: header.ModTime,
+			}
+		}
+	}
+	return a, nil
+}
+
+func (a *ArchiveFS) mkdirAll(name string, modTime time.Time) {
+	if _, ok := a.files[name]; ok {
+		return
+	}
+	a.mkdirAll(path.Dir(name), modTime)
+	a.files[name] = &archiveEntry{name: path.Base(name), mode: fs.ModeDir | 0555, modTime: modTime}
+	a.addChild(name)
+}
+
+func (a *ArchiveFS) addChild(name string) {
+	parent := a.files[path.Dir(name)]
+	parent.children = append(parent.children, path.Base(name))
+	sort.Strings(parent.children)
+}
+
+// Open implements fs.FS.
+func (a *ArchiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := a.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.mode.IsDir() {
+		return &archiveDir{fs: a, path: name, entry: e}, nil
+	}
+	return &archiveFile{entry: e, r: bytes.NewReader(e.data)}, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (a *ArchiveFS) ReadFile(name string) ([]byte, error) {
+	e, ok := a.files[name]
+	if !fs.ValidPath(name) || !ok || e.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), e.data...), nil
+}
+
+func (e *archiveEntry) Name() string               { return e.name }
+func (e *archiveEntry) Size() int64                { return int64(len(e.data)) }
+func (e *archiveEntry) Mode() fs.FileMode          { return e.mode }
+func (e *archiveEntry) ModTime() time.Time         { return e.modTime }
+func (e *archiveEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *archiveEntry) Sys() interface{}           { return nil }
+func (e *archiveEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *archiveEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+type archiveFile struct {
+	entry *archiveEntry
+	r     *bytes.Reader
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *archiveFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *archiveFile) Close() error               { return nil }
+
+type archiveDir struct {
+	fs     *ArchiveFS
+	path   string
+	entry  *archiveEntry
+	offset int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.entry, nil }
+func (d *archiveDir) Close() error               { return nil }
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entry.children[d.offset:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(remaining) {
+		remaining = remaining[:n]
+	}
+	entries := make([]fs.DirEntry, len(remaining))
+	for i, child := range remaining {
+		entries[i] = d.fs.files[path.Join(d.path, child)]
+	}
+	d.offset += len(remaining)
+	return entries, nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// tarEntry is an entry of a test archive; a name ending in / is a directory.
+type tarEntry struct {
+	name string
+	data string
+}
+
+func buildArchive(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(e.name, "/") {
+			header = &tar.Header{Name: e.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveFS(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		// files are the expected regular files and their contents
+		files map[string]string
+	}{
+		{
+			name: "packed layout",
+			entries: []tarEntry{
+				{name: "./"},
+				{name: "./colony.yaml", data: "name: app\n"},
+				{name: "./templates/"},
+				{name: "./templates/workflow.json", data: "{}"},
+			},
+			files: map[string]string{"colony.yaml": "name: app\n", "templates/workflow.json": "{}"},
+		},
+		{
+			name: "implicit directories",
+			entries: []tarEntry{
+				{name: "templates/nested/a.json", data: "a"},
+				{name: "values.yaml", data: "x: 1\n"},
+			},
+			files: map[string]string{"templates/nested/a.json": "a", "values.yaml": "x: 1\n"},
+		},
+		{
+			name: "later entry replaces earlier",
+			entries: []tarEntry{
+				{name: "values.yaml", data: "old"},
+				{name: "values.yaml", data: "new"},
+			},
+			files: map[string]string{"values.yaml": "new"},
+		},
+		{
+			name:    "empty archive",
+			entries: nil,
+			files:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := ReadArchive(bytes.NewReader(buildArchive(t, tt.entries)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expected []string
+			for name, data := range tt.files {
+				expected = append(expected, name)
+				got, err := fs.ReadFile(a, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != data {
+					t.Errorf("%s = %q, want %q", name, got, data)
+				}
+			}
+			if err := fstest.TestFS(a, expected...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestArchiveFSRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []byte
+		err     string
+	}{
+		{name: "parent path", archive: buildArchive(t, []tarEntry{{name: "../evil", data: "x"}}), err: "invalid path"},
+		{name: "nested parent path", archive: buildArchive(t, []tarEntry{{name: "a/../../evil", data: "x"}}), err: "invalid path"},
+		{name: "absolute path", archive: buildArchive(t, []tarEntry{{name: "/etc/passwd", data: "x"}}), err: "invalid path"},
+		{name: "not gzip", archive: []byte("plain text"), err: "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadArchive(bytes.NewReader(tt.archive))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestArchiveFSOpenErrors(t *testing.T) {
+	a, err := ReadArchive(bytes.NewReader(buildArchive(t, []tarEntry{{name: "templates/a.json", data: "a"}})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"missing.yaml", fs.ErrNotExist},
+		{"templates/b.json", fs.ErrNotExist},
+		{"../templates/a.json", fs.ErrInvalid},
+		{"/templates/a.json", fs.ErrInvalid},
+		{"templates/", fs.ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.Open(tt.name); !errors.Is(err, tt.err) {
+				t.Fatalf("Open(%q) error = %v, want %v", tt.name, err, tt.err)
+			}
+		})
+	}
+	if _, err := a.ReadFile("templates"); err == nil {
+		t.Fatal("ReadFile of a directory succeeded")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 
 	"path/filepath"
@@ -69,7 +70,34 @@ func (s *FsPackageService) LoadManifest(path string) (*domain.ColonyManifest, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to read manifest at %s: %w", manifestPath, err)
 	}
+	return parseManifest(data)
+}
+
+func (s *FsPackageService) LoadManifestFS(fsys fs.FS) (*domain.ColonyManifest, error) {
+	data, err := fs.ReadFile(fsys, "colony.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return parseManifest(data)
+}
+
+// Open returns a directory as an os.DirFS and reads an archive into memory.
+func (s *FsPackageService) Open(path string) (fs.FS, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(path), nil
+	}
+	archive, err := OpenArchive(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return archive, nil
+}
 
+func parseManifest(data []byte) (*domain.ColonyManifest, error) {
 	var manifest domain.ColonyManifest
 	if err := yaml.Unmarshal(data, &manifest); err != nil {
 		return nil, fmt.Errorf("failed to parse manifest: %w", err)
@@ -123,7 +151,9 @@ func (s *FsPackageService) Pack(path string, name string, version string) (strin
 		if err != nil {
 			return err
 		}
-		header.Name = relPath
+		// Archive paths are always slash separated, so packs made on
+		// Windows unpack the same everywhere
+		header.Name = filepath.ToSlash(relPath)
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
@@ -173,7 +203,7 @@ func (s *FsPackageService) Unpack(artifactPath string, destPath string) error {
 			return err
 		}
 
-		target := filepath.Join(destPath, header.Name)
+		target := filepath.Join(destPath, filepath.FromSlash(header.Name))
 
 		switch header.Typeflag {
 		case tar.TypeDir:
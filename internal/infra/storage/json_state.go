@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -50,7 +51,10 @@ func (s *JSONStateService) save(releases []*domain.Release) error {
 	return os.WriteFile(s.path, data, 0644)
 }
 
-func (s *JSONStateService) Save(release *domain.Release) error {
+func (s *JSONStateService) Save(ctx context.Context, release *domain.Release) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -75,13 +79,19 @@ func (s *JSONStateService) Save(release *domain.Release) error {
 	return s.save(releases)
 }
 
-func (s *JSONStateService) List() ([]*domain.Release, error) {
+func (s *JSONStateService) List(ctx context.Context) ([]*domain.Release, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.load()
 }
 
-func (s *JSONStateService) Get(name string) (*domain.Release, error) {
+func (s *JSONStateService) Get(ctx context.Context, name string) (*domain.Release, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -98,7 +108,10 @@ func (s *JSONStateService) Get(name string) (*domain.Release, error) {
 	return nil, fmt.Errorf("release %s not found", name)
 }
 
-func (s *JSONStateService) Delete(name string) error {
+func (s *JSONStateService) Delete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -0,0 +1,180 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/colonyos/cpm/pkg/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Patch modifies the rendered specs matching Target with either an
+// RFC 6902 JSON Patch or an RFC 7386 merge patch.
+type Patch struct {
+	Target     Target                 `yaml:"target,omitempty"`
+	JSONPatch  []Operation            `yaml:"jsonPatch,omitempty"`
+	MergePatch map[string]interface{} `yaml:"mergePatch,omitempty"`
+
+	// Source is the file the patch was loaded from
+	Source string `yaml:"-"`
+}
+
+// Target selects specs by name and kind. Empty fields match any spec.
+type Target struct {
+	Name string `yaml:"name,omitempty"`
+	Kind string `yaml:"kind,omitempty"`
+}
+
+// Operation is a single RFC 6902 operation.
+type Operation struct {
+	Op    string      `yaml:"op"`
+	Path  string      `yaml:"path"`
+	From  string      `yaml:"from,omitempty"`
+	Value interface{} `yaml:"value,omitempty"`
+}
+
+// file is the layout of a patch file, which is also the layout of the
+// patches key in a values-<env>.yaml profile.
+type file struct {
+	Patches []Patch `yaml:"patches"`
+}
+
+// Load reads the patches key of a YAML or JSON file. Other keys are
+// ignored, so the same function reads patch files and values profiles.
+func Load(path string) ([]Patch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch file %s: %w", path, err)
+	}
+	return parse(path, data)
+}
+
+// LoadFS is Load for a file in a package rooted at fsys.
+func LoadFS(fsys fs.FS, name string) ([]Patch, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch file %s: %w", name, err)
+	}
+	return parse(name, data)
+}
+
+func parse(path string, data []byte) ([]Patch, error) {
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse patches in %s: %w", path, err)
+	}
+
+	for i := range f.Patches {
+		f.Patches[i].Source = path
+		if err := f.Patches[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: patch %d: %w", path, i+1, err)
+		}
+	}
+	return f.Patches, nil
+}
+
+func (p *Patch) validate() error {
+	if (len(p.JSONPatch) == 0) == (p.MergePatch == nil) {
+		return fmt.Errorf("exactly one of jsonPatch or mergePatch must be set")
+	}
+	for _, op := range p.JSONPatch {
+		switch op.Op {
+		case "add", "remove", "replace", "test":
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return fmt.Errorf("%s: invalid from: %w", op.Op, err)
+			}
+		default:
+			return fmt.Errorf("unknown op %q", op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return fmt.Errorf("%s: invalid path: %w", op.Op, err)
+		}
+	}
+	return nil
+}
+
+// Apply applies the patches in order to the specs that match their
+// targets and returns the patched specs. A patch that matches no spec is
+// an error, so a renamed spec cannot silently drop a patch.
+func Apply(specs []map[string]interface{}, patches []Patch) ([]map[string]interface{}, error) {
+	for i, p := range patches {
+		matched := false
+		for j, spec := range specs {
+			if !p.Target.matches(spec) {
+				continue
+			}
+			matched = true
+
+			patched, err := p.apply(spec)
+			if err != nil {
+				return nil, fmt.Errorf("%s: patch %d on %s %q: %w", p.Source, i+1, domain.SpecKind(spec), domain.SpecName(spec), err)
+			}
+			specs[j] = patched
+		}
+		if !matched {
+			return nil, fmt.Errorf("%s: patch %d matches no spec (%s)", p.Source, i+1, p.Target)
+		}
+	}
+	return specs, nil
+}
+
+func (p *Patch) apply(spec map[string]interface{}) (map[string]interface{}, error) {
+	var doc interface{} = spec
+	var err error
+	if p.MergePatch != nil {
+		doc = mergePatch(doc, normalize(p.MergePatch))
+	} else {
+		doc, err = applyOperations(doc, p.JSONPatch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	patched, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("patched spec is a %T, not an object", doc)
+	}
+	return patched, nil
+}
+
+func (t Target) matches(spec map[string]interface{}) bool {
+	if t.Name != "" && t.Name != domain.SpecName(spec) {
+		return false
+	}
+	if t.Kind != "" && !strings.EqualFold(t.Kind, domain.SpecKind(spec)) {
+		return false
+	}
+	return true
+}
+
+func (t Target) String() string {
+	var parts []string
+	if t.Name != "" {
+		parts = append(parts, "name="+t.Name)
+	}
+	if t.Kind != "" {
+		parts = append(parts, "kind="+t.Kind)
+	}
+	if len(parts) == 0 {
+		return "any"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// normalize converts a value decoded from YAML to the types produced by
+// encoding/json, and returns a deep copy of it.
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
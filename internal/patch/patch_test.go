@@ -0,0 +1,190 @@
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+// TestJSONPatch runs the examples of RFC 6902, appendix A.
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		// err is a substring of the expected error
+		err string
+	}{
+		{name: "add object member", doc: `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`, want: `{"baz":"qux","foo":"bar"}`},
+		{name: "add array element", doc: `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`, want: `{"foo":["bar","qux","baz"]}`},
+		{name: "remove object member", doc: `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`, want: `{"foo":"bar"}`},
+		{name: "remove array element", doc: `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`, want: `{"foo":["bar","baz"]}`},
+		{name: "replace value", doc: `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`, want: `{"baz":"boo","foo":"bar"}`},
+		{name: "move value", doc: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{name: "move array element", doc: `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, want: `{"foo":["all","cows","eat","grass"]}`},
+		{name: "test success", doc: `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`},
+		{name: "test failure", doc: `{"baz":"qux"}`,
+			patch: `[{"op":"test","path":"/baz","value":"bar"}]`, err: "test failed"},
+		{name: "add nested member", doc: `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, want: `{"foo":"bar","child":{"grandchild":{}}}`},
+		{name: "add to nonexistent target", doc: `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`, err: `member "baz" not found`},
+		{name: "escaped pointer", doc: `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":10},{"op":"replace","path":"/~1","value":8}]`, want: `{"/":8,"~1":10}`},
+		{name: "add array value", doc: `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, want: `{"foo":["bar",["abc","def"]]}`},
+		{name: "copy value", doc: `{"a":{"b":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, want: `{"a":{"b":1},"c":{"b":2}}`},
+		{name: "index out of bounds", doc: `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/2","value":"x"}]`, err: "out of bounds"},
+		{name: "leading zero index", doc: `{"foo":["a","b"]}`,
+			patch: `[{"op":"remove","path":"/foo/01"}]`, err: "invalid array index"},
+		{name: "move into own child", doc: `{"a":{"b":{}}}`,
+			patch: `[{"op":"move","from":"/a","path":"/a/b/c"}]`, err: "into one of its children"},
+		{name: "remove missing member", doc: `{"a":1}`,
+			patch: `[{"op":"remove","path":"/b"}]`, err: `member "b" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []Operation
+			if err := json.Unmarshal([]byte(tt.patch), &ops); err != nil {
+				t.Fatal(err)
+			}
+			got, err := applyOperations(decode(t, tt.doc), ops)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("patched document = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestMergePatch runs the examples of RFC 7386, appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target+" "+tt.patch, func(t *testing.T) {
+			got := mergePatch(decode(t, tt.target), decode(t, tt.patch))
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("merged = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyTargets(t *testing.T) {
+	specs := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"name": "train", "funcName": "train"},
+			{"name": "pipeline", "functionspecs": []interface{}{}},
+		}
+	}
+	tests := []struct {
+		name    string
+		patches []Patch
+		want    []string
+		err     string
+	}{
+		{name: "any target", patches: []Patch{{MergePatch: map[string]interface{}{"env": "prod"}}},
+			want: []string{"prod", "prod"}},
+		{name: "by name", patches: []Patch{{Target: Target{Name: "pipeline"}, MergePatch: map[string]interface{}{"env": "prod"}}},
+			want: []string{"", "prod"}},
+		{name: "by kind", patches: []Patch{{Target: Target{Kind: "Function"}, JSONPatch: []Operation{{Op: "add", Path: "/env", Value: "prod"}}}},
+			want: []string{"prod", ""}},
+		{name: "later patch wins", patches: []Patch{
+			{MergePatch: map[string]interface{}{"env": "dev"}},
+			{Target: Target{Name: "train"}, MergePatch: map[string]interface{}{"env": "prod"}},
+		}, want: []string{"prod", "dev"}},
+		{name: "no match", patches: []Patch{{Source: "p.yaml", Target: Target{Name: "missing"}, MergePatch: map[string]interface{}{"env": "prod"}}},
+			err: "p.yaml: patch 1 matches no spec (name=missing)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(specs(), tt.patches)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, spec := range got {
+				env, _ := spec["env"].(string)
+				if env != tt.want[i] {
+					t.Errorf("spec %d env = %q, want %q", i, env, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"both kinds", "patches:\n  - mergePatch: {a: 1}\n    jsonPatch: [{op: add, path: /a, value: 1}]\n", "exactly one of"},
+		{"neither kind", "patches:\n  - target: {name: a}\n", "exactly one of"},
+		{"unknown op", "patches:\n  - jsonPatch: [{op: frob, path: /a}]\n", `unknown op "frob"`},
+		{"relative path", "patches:\n  - jsonPatch: [{op: remove, path: a}]\n", "must start with /"},
+		{"relative from", "patches:\n  - jsonPatch: [{op: move, from: a, path: /b}]\n", "invalid from"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse("p.yaml", []byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
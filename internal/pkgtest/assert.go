@@ -0,0 +1,189 @@
+package pkgtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Assertion is a single check on the rendered specs. Exactly one field
+// should be set.
+type Assertion struct {
+	Equal        *PathValue    `yaml:"equal,omitempty"`
+	NotEqual     *PathValue    `yaml:"notEqual,omitempty"`
+	Contains     *PathValue    `yaml:"contains,omitempty"`
+	NotContains  *PathValue    `yaml:"notContains,omitempty"`
+	Exists       *Path         `yaml:"exists,omitempty"`
+	NotExists    *Path         `yaml:"notExists,omitempty"`
+	Count        *int          `yaml:"count,omitempty"`
+	FailedRender *FailedRender `yaml:"failedRender,omitempty"`
+}
+
+// Path selects values with a JSONPath expression. Without Document the
+// path is evaluated against the array of all rendered specs, so "$[0].name"
+// is the name of the first spec. With Document it is evaluated against that
+// spec only.
+type Path struct {
+	Path     string `yaml:"path"`
+	Document *int   `yaml:"document,omitempty"`
+}
+
+// PathValue is a Path with an expected value.
+type PathValue struct {
+	Path  `yaml:",inline"`
+	Value interface{} `yaml:"value"`
+}
+
+// FailedRender expects rendering to fail.
+type FailedRender struct {
+	ErrorContains string `yaml:"errorContains,omitempty"`
+}
+
+// ExpectsRenderError reports whether the assertion checks for a render error.
+func (a Assertion) ExpectsRenderError() bool {
+	return a.FailedRender != nil
+}
+
+// Check runs the assertion against the rendered specs, or the error
+// returned by rendering. It returns a description of the failure, or nil.
+func (a Assertion) Check(docs []interface{}, renderErr error) error {
+	if a.FailedRender != nil {
+		if renderErr == nil {
+			return fmt.Errorf("failedRender: expected rendering to fail, but it succeeded")
+		}
+		if !strings.Contains(renderErr.Error(), a.FailedRender.ErrorContains) {
+			return fmt.Errorf("failedRender: expected error containing %q, got: %v", a.FailedRender.ErrorContains, renderErr)
+		}
+		return nil
+	}
+
+	if renderErr != nil {
+		return fmt.Errorf("render failed: %v", renderErr)
+	}
+
+	switch {
+	case a.Count != nil:
+		if len(docs) != *a.Count {
+			return fmt.Errorf("count: expected %d documents, got %d", *a.Count, len(docs))
+		}
+	case a.Exists != nil:
+		matches, err := a.Exists.eval(docs)
+		if err != nil {
+			return fmt.Errorf("exists: %w", err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("exists: nothing found at %s", a.Exists.Path)
+		}
+	case a.NotExists != nil:
+		matches, err := a.NotExists.eval(docs)
+		if err != nil {
+			return fmt.Errorf("notExists: %w", err)
+		}
+		if len(matches) > 0 {
+			return fmt.Errorf("notExists: found %s at %s", format(matches[0]), a.NotExists.Path)
+		}
+	case a.Equal != nil:
+		return a.Equal.check("equal", docs, func(actual, expected interface{}) bool {
+			return reflect.DeepEqual(actual, expected)
+		}, true)
+	case a.NotEqual != nil:
+		return a.NotEqual.check("notEqual", docs, func(actual, expected interface{}) bool {
+			return !reflect.DeepEqual(actual, expected)
+		}, false)
+	case a.Contains != nil:
+		return a.Contains.check("contains", docs, contains, true)
+	case a.NotContains != nil:
+		return a.NotContains.check("notContains", docs, func(actual, expected interface{}) bool {
+			return !contains(actual, expected)
+		}, false)
+	default:
+		return fmt.Errorf("assertion has no check")
+	}
+	return nil
+}
+
+func (p Path) eval(docs []interface{}) ([]interface{}, error) {
+	var root interface{} = docs
+	if p.Document != nil {
+		if *p.Document < 0 || *p.Document >= len(docs) {
+			return nil, fmt.Errorf("document %d does not exist (%d rendered)", *p.Document, len(docs))
+		}
+		root = docs[*p.Document]
+	}
+	return evalPath(root, p.Path)
+}
+
+// check evaluates the path and applies match to every value found. If
+// mustExist is set, a path that matches nothing is a failure.
+func (p PathValue) check(name string, docs []interface{}, match func(actual, expected interface{}) bool, mustExist bool) error {
+	matches, err := p.eval(docs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if len(matches) == 0 {
+		if mustExist {
+			return fmt.Errorf("%s: nothing found at %s", name, p.Path.Path)
+		}
+		return nil
+	}
+
+	expected := normalize(p.Value)
+	for _, actual := range matches {
+		if !match(actual, expected) {
+			return fmt.Errorf("%s: %s\n  expected: %s\n  actual:   %s", name, p.Path.Path, format(expected), format(actual))
+		}
+	}
+	return nil
+}
+
+// contains reports whether actual contains expected: an element of a list,
+// a substring of a string, or a subset of a map.
+func contains(actual, expected interface{}) bool {
+	switch t := actual.(type) {
+	case []interface{}:
+		for _, v := range t {
+			if reflect.DeepEqual(v, expected) {
+				return true
+			}
+		}
+	case string:
+		if s, ok := expected.(string); ok {
+			return strings.Contains(t, s)
+		}
+	case map[string]interface{}:
+		sub, ok := expected.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range sub {
+			if !reflect.DeepEqual(t[k], v) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// normalize round-trips v through JSON so YAML ints compare equal to the
+// float64s produced by decoding the rendered specs.
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func format(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,110 @@
+package pkgtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one segment of a parsed path: a map key, a list index or a
+// wildcard over either.
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parsePath parses the JSONPath subset used by assertions: $, .key,
+// ['key'], [n] and [*]/.*. A path without a leading $ is relative to the
+// root, so "name" and "$.name" are the same.
+func parsePath(path string) ([]pathStep, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+	if p != "" && p[0] != '.' && p[0] != '[' {
+		p = "." + p
+	}
+
+	var steps []pathStep
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			name := p[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			if name == "*" {
+				steps = append(steps, pathStep{wildcard: true})
+			} else {
+				steps = append(steps, pathStep{key: name})
+			}
+			p = p[end:]
+		case '[':
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", path)
+			}
+			inner := strings.TrimSpace(p[1:end])
+			p = p[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, pathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, pathStep{key: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: bad index %q", path, inner)
+				}
+				steps = append(steps, pathStep{index: idx, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid path %q at %q", path, p)
+		}
+	}
+	return steps, nil
+}
+
+// evalPath returns every value in doc matched by path.
+func evalPath(doc interface{}, path string) ([]interface{}, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := []interface{}{doc}
+	for _, step := range steps {
+		var next []interface{}
+		for _, node := range current {
+			switch t := node.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					for _, v := range t {
+						next = append(next, v)
+					}
+				} else if v, ok := t[step.key]; ok && !step.isIndex {
+					next = append(next, v)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, t...)
+				} else if step.isIndex {
+					idx := step.index
+					if idx < 0 {
+						idx += len(t)
+					}
+					if idx >= 0 && idx < len(t) {
+						next = append(next, t[idx])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current, nil
+}
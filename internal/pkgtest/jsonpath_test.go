@@ -0,0 +1,103 @@
+package pkgtest
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const pathDoc = `[
+  {
+    "name": "pipeline",
+    "labels": {"team": "ml", "tier.name": "gold"},
+    "functionspecs": [
+      {"nodename": "prepare", "args": ["a", "b"]},
+      {"nodename": "train", "args": ["c"]}
+    ]
+  },
+  {"name": "train", "funcName": "train"}
+]`
+
+func TestEvalPath(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(pathDoc), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want []interface{}
+		// unordered compares results sorted, for wildcards over maps
+		unordered bool
+	}{
+		{path: "$[0].name", want: []interface{}{"pipeline"}},
+		{path: "[1].funcName", want: []interface{}{"train"}},
+		{path: "$[-1].name", want: []interface{}{"train"}},
+		{path: "$[0].functionspecs[1].nodename", want: []interface{}{"train"}},
+		{path: "$[*].name", want: []interface{}{"pipeline", "train"}},
+		{path: "$.*.name", want: []interface{}{"pipeline", "train"}},
+		{path: "$[0].functionspecs[*].args[0]", want: []interface{}{"a", "c"}},
+		{path: "$[0]['labels']['tier.name']", want: []interface{}{"gold"}},
+		{path: `$[0].labels["team"]`, want: []interface{}{"ml"}},
+		{path: "$[0].labels.*", want: []interface{}{"gold", "ml"}, unordered: true},
+		{path: " $[0].name ", want: []interface{}{"pipeline"}},
+		{path: "$[5].name", want: nil},
+		{path: "$[0].missing", want: nil},
+		{path: "$[0].name[0]", want: nil},
+		{path: "$[0].labels[0]", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := evalPath(doc, tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.unordered {
+				sort.Slice(got, func(i, j int) bool { return got[i].(string) < got[j].(string) })
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("evalPath(%q) = %#v, want %#v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalRelativePath(t *testing.T) {
+	doc := map[string]interface{}{"name": "app", "nested": map[string]interface{}{"key": 1.0}}
+	for _, path := range []string{"name", "$.name", ".name"} {
+		got, err := evalPath(doc, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, []interface{}{"app"}) {
+			t.Errorf("evalPath(%q) = %#v, want [app]", path, got)
+		}
+	}
+	if got, _ := evalPath(doc, "nested.key"); !reflect.DeepEqual(got, []interface{}{1.0}) {
+		t.Errorf("evalPath(nested.key) = %#v, want [1]", got)
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	tests := []struct {
+		path string
+		err  string
+	}{
+		{"$..name", "empty key"},
+		{"$.", "empty key"},
+		{"$[0", "missing ]"},
+		{"$[x]", `bad index "x"`},
+		{"$['a]", "bad index"},
+		{"$[0]name", "invalid path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := parsePath(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("parsePath(%q) error = %v, want one containing %q", tt.path, err, tt.err)
+			}
+		})
+	}
+}
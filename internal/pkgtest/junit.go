@@ -0,0 +1,112 @@
+package pkgtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of one test case.
+type Result struct {
+	Suite    string
+	File     string
+	Name     string
+	Failures []string
+	Duration time.Duration
+	// SnapshotWritten is set when the case snapshot was created or updated
+	SnapshotWritten bool
+}
+
+// Passed reports whether every assertion in the case passed.
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit XML report, one testsuite per
+// suite file.
+func WriteJUnit(w io.Writer, results []Result) error {
+	report := junitTestSuites{}
+	var total time.Duration
+	index := make(map[string]int)
+	var durations []time.Duration
+
+	for _, r := range results {
+		i, ok := index[r.File]
+		if !ok {
+			i = len(report.Suites)
+			index[r.File] = i
+			report.Suites = append(report.Suites, junitTestSuite{Name: r.Suite})
+			durations = append(durations, 0)
+		}
+		suite := &report.Suites[i]
+
+		tc := junitTestCase{
+			Name:      r.Name,
+			ClassName: r.File,
+			Time:      seconds(r.Duration),
+		}
+		if !r.Passed() {
+			tc.Failure = &junitFailure{
+				Message: r.Failures[0],
+				Text:    strings.Join(r.Failures, "\n"),
+			}
+			suite.Failures++
+			report.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+		suite.Tests++
+		report.Tests++
+		durations[i] += r.Duration
+		total += r.Duration
+	}
+
+	for i := range report.Suites {
+		report.Suites[i].Time = seconds(durations[i])
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,148 @@
+package pkgtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SnapshotDir is the directory, inside tests/, that holds snapshot files.
+const SnapshotDir = "__snapshots__"
+
+// Snapshots holds the stored rendered output of every case in a suite,
+// keyed by case name. It is saved as tests/__snapshots__/<suite file>.snap.
+type Snapshots struct {
+	path    string
+	entries map[string]string
+	dirty   bool
+}
+
+// LoadSnapshots reads the snapshot file for a suite. A missing file yields
+// an empty set.
+func LoadSnapshots(packagePath string, suite *Suite) (*Snapshots, error) {
+	path := filepath.Join(packagePath, Dir, SnapshotDir, filepath.Base(suite.File)+".snap")
+	s := &Snapshots{path: path, entries: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]string)
+	}
+	return s, nil
+}
+
+// Get returns the stored snapshot for a case.
+func (s *Snapshots) Get(name string) (string, bool) {
+	v, ok := s.entries[name]
+	return v, ok
+}
+
+// Set stores the snapshot for a case.
+func (s *Snapshots) Set(name, content string) {
+	if old, ok := s.entries[name]; ok && old == content {
+		return
+	}
+	s.entries[name] = content
+	s.dirty = true
+}
+
+// Prune removes snapshots of cases that no longer exist in the suite.
+func (s *Snapshots) Prune(suite *Suite) {
+	keep := make(map[string]bool)
+	for _, tc := range suite.Tests {
+		keep[tc.Name] = true
+	}
+	for name := range s.entries {
+		if !keep[name] {
+			delete(s.entries, name)
+			s.dirty = true
+		}
+	}
+}
+
+// Save writes the snapshot file if anything changed.
+func (s *Snapshots) Save() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+
+	file, err := os.Create(s.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := yaml.NewEncoder(file)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(s.entries); err != nil {
+		return fmt.Errorf("failed to write snapshot %s: %w", s.path, err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// Render formats rendered specs for a snapshot. Map keys are sorted, so the
+// output is stable between runs.
+func Render(docs []interface{}) (string, error) {
+	data, err := json.MarshalIndent(docs, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// Diff returns a line diff between the stored and the current snapshot,
+// with removed lines prefixed by "-" and added lines by "+".
+func Diff(stored, current string) string {
+	a := strings.Split(strings.TrimSuffix(stored, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(current, "\n"), "\n")
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&sb, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&sb, "+ %s\n", b[j])
+			j++
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
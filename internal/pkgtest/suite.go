@@ -0,0 +1,72 @@
+package pkgtest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dir is the directory in a package that holds test suites.
+const Dir = "tests"
+
+// Suite is a file of test cases in the tests/ directory.
+type Suite struct {
+	Name  string `yaml:"suite"`
+	Tests []Case `yaml:"tests"`
+
+	// File is the path of the suite, relative to the package
+	File string `yaml:"-"`
+}
+
+// Case renders the package with a set of values and checks the result.
+type Case struct {
+	Name string `yaml:"name"`
+	// Env selects a values-<env>.yaml profile
+	Env string `yaml:"env,omitempty"`
+	// Values are files, relative to the suite, merged in order
+	Values []string `yaml:"values,omitempty"`
+	// Set overrides values, like --set
+	Set     map[string]interface{} `yaml:"set,omitempty"`
+	Asserts []Assertion            `yaml:"asserts"`
+}
+
+// LoadSuites reads the test suites of the package in the directory
+// packagePath, see LoadSuitesFS.
+func LoadSuites(packagePath string) ([]*Suite, error) {
+	return LoadSuitesFS(os.DirFS(packagePath))
+}
+
+// LoadSuitesFS reads every .yaml file directly in the tests/ directory of
+// the package rooted at fsys, sorted by file name.
+func LoadSuitesFS(fsys fs.FS) ([]*Suite, error) {
+	matches, err := fs.Glob(fsys, Dir+"/*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	var suites []*Suite
+	for _, m := range matches {
+		data, err := fs.ReadFile(fsys, m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read test suite %s: %w", m, err)
+		}
+
+		suite := &Suite{}
+		if err := yaml.Unmarshal(data, suite); err != nil {
+			return nil, fmt.Errorf("failed to parse test suite %s: %w", m, err)
+		}
+
+		suite.File = m
+		if suite.Name == "" {
+			suite.Name = strings.TrimSuffix(path.Base(m), ".yaml")
+		}
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
@@ -0,0 +1,292 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/nacl/box"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the encrypted secrets file in a package.
+const FileName = "secrets.yaml"
+
+// Encrypted values are stored as ENC[box:<base64 sealed box>]. The sealed
+// message is the YAML encoding of the plaintext value, so ints and bools
+// keep their type after decryption.
+const (
+	encPrefix = "ENC[box:"
+	encSuffix = "]"
+)
+
+// IsEncrypted reports whether s is an encrypted secrets value.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, encPrefix) && strings.HasSuffix(s, encSuffix)
+}
+
+func (k *Keyring) encryptValue(v interface{}) (string, error) {
+	payload, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := box.SealAnonymous(nil, payload, k.publicKey, rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt value: %w", err)
+	}
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed) + encSuffix, nil
+}
+
+func (k *Keyring) decryptValue(s string) (interface{}, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(s, encPrefix), encSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	payload, ok := box.OpenAnonymous(nil, sealed, k.publicKey, k.privateKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt value: it was encrypted with a different key")
+	}
+
+	var v interface{}
+	if err := yaml.Unmarshal(payload, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode decrypted value: %w", err)
+	}
+	return v, nil
+}
+
+// EncryptFile encrypts every plaintext value in a secrets file in place,
+// leaving keys, comments and already encrypted values untouched. A key
+// pair is generated on first use. It returns the number of values encrypted
+// and whether a new key was generated.
+func (k *Keyring) EncryptFile(path string) (int, bool, error) {
+	generated, err := k.loadOrGenerate()
+	if err != nil {
+		return 0, false, err
+	}
+
+	doc, err := readNode(path)
+	if err != nil {
+		return 0, generated, err
+	}
+
+	count := 0
+	err = walkLeaves(doc, func(n *yaml.Node) error {
+		if IsEncrypted(n.Value) || n.Tag == "!!null" {
+			return nil
+		}
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return err
+		}
+		enc, err := k.encryptValue(v)
+		if err != nil {
+			return err
+		}
+		n.Tag = "!!str"
+		n.Style = 0
+		n.Value = enc
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, generated, err
+	}
+
+	if err := writeNode(path, doc); err != nil {
+		return 0, generated, err
+	}
+	return count, generated, nil
+}
+
+// DecryptFile returns the contents of a secrets file with every value
+// decrypted. The file itself is not modified.
+func (k *Keyring) DecryptFile(path string) ([]byte, error) {
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+
+	doc, err := readNode(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkLeaves(doc, func(n *yaml.Node) error {
+		if !IsEncrypted(n.Value) {
+			return nil
+		}
+		v, err := k.decryptValue(n.Value)
+		if err != nil {
+			return err
+		}
+		var plain yaml.Node
+		if err := plain.Encode(v); err != nil {
+			return err
+		}
+		plain.HeadComment, plain.LineComment, plain.FootComment = n.HeadComment, n.LineComment, n.FootComment
+		*n = plain
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeNode(doc)
+}
+
+// DecryptValues returns a copy of values with every encrypted string
+// replaced by its plaintext.
+func (k *Keyring) DecryptValues(values map[string]interface{}) (map[string]interface{}, error) {
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	v, err := k.decryptTree(values)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+func (k *Keyring) decryptTree(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for key, val := range t {
+			dec, err := k.decryptTree(val)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			out[key] = dec
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			dec, err := k.decryptTree(val)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = dec
+		}
+		return out, nil
+	case string:
+		if IsEncrypted(t) {
+			return k.decryptValue(t)
+		}
+	}
+	return v, nil
+}
+
+// PlaintextPaths returns the dotted paths of values in a secrets map that
+// are not encrypted.
+func PlaintextPaths(values map[string]interface{}) []string {
+	var paths []string
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for key, val := range t {
+				p := key
+				if prefix != "" {
+					p = prefix + "." + key
+				}
+				walk(p, val)
+			}
+		case []interface{}:
+			for i, val := range t {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), val)
+			}
+		case string:
+			if !IsEncrypted(t) {
+				paths = append(paths, prefix)
+			}
+		case nil:
+		default:
+			paths = append(paths, prefix)
+		}
+	}
+	walk("", values)
+	return paths
+}
+
+// MaskEncrypted returns a copy of values with every encrypted string
+// replaced by Mask. It lets secrets files be used without the key, e.g.
+// when linting.
+func MaskEncrypted(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for key, val := range t {
+			out[key] = MaskEncrypted(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = MaskEncrypted(val)
+		}
+		return out
+	case string:
+		if IsEncrypted(t) {
+			return Mask
+		}
+	}
+	return v
+}
+
+func readNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &doc, nil
+}
+
+func writeNode(path string, doc *yaml.Node) error {
+	data, err := encodeNode(doc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func encodeNode(doc *yaml.Node) ([]byte, error) {
+	if doc.Kind == 0 {
+		// Empty file
+		return []byte{}, nil
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// walkLeaves calls fn for every scalar value (not key) in the document.
+func walkLeaves(n *yaml.Node, fn func(*yaml.Node) error) error {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := walkLeaves(c, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := walkLeaves(n.Content[i], fn); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		return fn(n)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const keyFileName = "secrets.key"
+
+// Keyring holds the local X25519 key pair used to encrypt and decrypt
+// package secrets. The key is stored base64 encoded in $CPM_HOME/keys.
+type Keyring struct {
+	dir        string
+	publicKey  *[32]byte
+	privateKey *[32]byte
+}
+
+// NewKeyring returns a keyring backed by the keys directory in cpmHome.
+// The key pair is loaded lazily, so packages without secrets never need one.
+func NewKeyring(cpmHome string) *Keyring {
+	return &Keyring{dir: filepath.Join(cpmHome, "keys")}
+}
+
+// KeyPath returns the path of the private key file.
+func (k *Keyring) KeyPath() string {
+	return filepath.Join(k.dir, keyFileName)
+}
+
+// load reads the key pair from disk.
+func (k *Keyring) load() error {
+	if k.privateKey != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(k.KeyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no secrets key found at %s (run 'cpm secrets encrypt' to create one)", k.KeyPath())
+		}
+		return fmt.Errorf("failed to read secrets key: %w", err)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid secrets key: %w", err)
+	}
+	if len(raw) != 32 {
+		return fmt.Errorf("invalid secrets key length: got %d, want 32", len(raw))
+	}
+
+	var priv, pub [32]byte
+	copy(priv[:], raw)
+	derived, err := curve25519.X25519(priv[:], curve25519.Basepoint)
+	if err != nil {
+		return fmt.Errorf("invalid secrets key: %w", err)
+	}
+	copy(pub[:], derived)
+
+	k.privateKey = &priv
+	k.publicKey = &pub
+	return nil
+}
+
+// loadOrGenerate loads the key pair, creating a new one if none exists.
+// It reports whether a new key was generated.
+func (k *Keyring) loadOrGenerate() (bool, error) {
+	if _, err := os.Stat(k.KeyPath()); err == nil {
+		return false, k.load()
+	}
+
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate secrets key: %w", err)
+	}
+
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return false, fmt.Errorf("failed to create keys directory: %w", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(priv[:]) + "\n"
+	if err := os.WriteFile(k.KeyPath(), []byte(encoded), 0600); err != nil {
+		return false, fmt.Errorf("failed to write secrets key: %w", err)
+	}
+
+	k.privateKey = priv
+	k.publicKey = pub
+	return true, nil
+}
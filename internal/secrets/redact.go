@@ -0,0 +1,244 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask replaces secret values in output.
+const Mask = "[REDACTED]"
+
+// MinSecretLength is the length a string secret needs to be masked
+// wherever it appears in text. Shorter secrets, and secrets that are not
+// strings such as numbers, would mask parts of unrelated IDs and numbers
+// that contain them, so in text they are only masked as whole words, e.g.
+// a secret port 4242 in "host:4242/api" but not in "a4242f".
+const MinSecretLength = 4
+
+// Redactor masks known secret values in strings, errors, values maps and
+// specs.
+type Redactor struct {
+	// secrets are the text forms of the secret values, longest first
+	secrets []secret
+	// values are masked where a whole value equals them, keyed by fmt.Sprint
+	values map[string]bool
+}
+
+// secret is the text form of a secret value.
+type secret struct {
+	text string
+	// word restricts matches to whole words
+	word bool
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{values: map[string]bool{}}
+}
+
+// AddValues registers every scalar in values as a secret.
+func (r *Redactor) AddValues(values map[string]interface{}) {
+	r.add(values)
+	// Match longer secrets first so a secret containing another one is
+	// masked as a whole.
+	sort.SliceStable(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i].text) > len(r.secrets[j].text)
+	})
+}
+
+func (r *Redactor) add(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, val := range t {
+			r.add(val)
+		}
+	case []interface{}:
+		for _, val := range t {
+			r.add(val)
+		}
+	case nil, bool:
+		// Too common to be worth masking
+	case string:
+		if t == "" {
+			return
+		}
+		r.values[t] = true
+		word := len(t) < MinSecretLength
+		r.secrets = append(r.secrets, secret{text: t, word: word})
+		// Secrets rendered into JSON strings appear in escaped form
+		if escaped, err := json.Marshal(t); err == nil {
+			if e := string(escaped[1 : len(escaped)-1]); e != t {
+				r.secrets = append(r.secrets, secret{text: e, word: word})
+			}
+		}
+	default:
+		text := fmt.Sprint(t)
+		r.values[text] = true
+		r.secrets = append(r.secrets, secret{text: text, word: true})
+	}
+}
+
+// caretLine matches the line error snippets put under a source line, with
+// a caret under the offending column, e.g. "    | \t  ^".
+var caretLine = regexp.MustCompile(`^( *\| )([ \t]*)\^$`)
+
+// Redact returns s with every secret replaced by Mask. A caret line under
+// a line with a masked secret is moved to keep pointing at the same text.
+func (r *Redactor) Redact(s string) string {
+	if r == nil || len(r.secrets) == 0 {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		var m []string
+		if i+1 < len(lines) {
+			m = caretLine.FindStringSubmatch(lines[i+1])
+		}
+		if m == nil || len(m[1]) > len(line) {
+			lines[i], _ = r.redactLine(line, -1)
+			continue
+		}
+
+		// The caret is under the text that follows the snippet prefix
+		prefix, text := line[:len(m[1])], line[len(m[1]):]
+		col := byteOffset(text, utf8.RuneCountInString(m[2]))
+		redacted, moved := r.redactLine(text, col)
+		lines[i] = prefix + redacted
+		lines[i+1] = m[1] + strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return '\t'
+			}
+			return ' '
+		}, redacted[:moved]) + "^"
+		i++
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactLine masks the secrets in line and returns it with offset, a byte
+// offset in line, moved to the same text in the result. An offset inside
+// a secret moves to the start of its mask.
+func (r *Redactor) redactLine(line string, offset int) (string, int) {
+	var sb strings.Builder
+	moved := offset
+	for i := 0; i < len(line); {
+		n := r.match(line, i)
+		if n == 0 {
+			sb.WriteByte(line[i])
+			i++
+			continue
+		}
+		switch {
+		case offset >= i+n:
+			moved += len(Mask) - n
+		case offset >= i:
+			moved = sb.Len()
+		}
+		sb.WriteString(Mask)
+		i += n
+	}
+	return sb.String(), moved
+}
+
+// match returns the length of the secret at line[i:], or 0 if there is
+// none.
+func (r *Redactor) match(line string, i int) int {
+	for _, s := range r.secrets {
+		if !strings.HasPrefix(line[i:], s.text) {
+			continue
+		}
+		end := i + len(s.text)
+		if s.word && (i > 0 && isWordByte(line[i-1]) || end < len(line) && isWordByte(line[end])) {
+			continue
+		}
+		return len(s.text)
+	}
+	return 0
+}
+
+// isWordByte reports whether b continues a word, so a whole word secret
+// next to it is part of something else. Bytes of multi-byte characters
+// count as word bytes.
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= utf8.RuneSelf
+}
+
+// byteOffset returns the byte offset of the n-th rune of s.
+func byteOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+// RedactError returns err with secrets masked in its message.
+func (r *Redactor) RedactError(err error) error {
+	if err == nil || r == nil || len(r.secrets) == 0 {
+		return err
+	}
+	return &redactedError{msg: r.Redact(err.Error()), err: err}
+}
+
+// RedactValues returns a copy of values with secrets masked, suitable for
+// storing in a release record.
+func (r *Redactor) RedactValues(values map[string]interface{}) map[string]interface{} {
+	return r.redactTree(values).(map[string]interface{})
+}
+
+// RedactSpecs returns a copy of the rendered specs with secrets masked.
+// Masking the decoded specs rather than their JSON keeps the output valid
+// JSON: a masked number becomes the string Mask.
+func (r *Redactor) RedactSpecs(specs []map[string]interface{}) []map[string]interface{} {
+	if r == nil {
+		return specs
+	}
+	out := make([]map[string]interface{}, len(specs))
+	for i, spec := range specs {
+		out[i] = r.redactTree(spec).(map[string]interface{})
+	}
+	return out
+}
+
+// redactTree masks values equal to a secret and secrets inside strings.
+func (r *Redactor) redactTree(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for key, val := range t {
+			out[key] = r.redactTree(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = r.redactTree(val)
+		}
+		return out
+	case string:
+		if r.values[t] {
+			return Mask
+		}
+		return r.Redact(t)
+	case nil, bool:
+		return v
+	}
+	if r.values[fmt.Sprint(v)] {
+		return Mask
+	}
+	return v
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
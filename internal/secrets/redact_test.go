@@ -0,0 +1,44 @@
+package secrets
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	r := NewRedactor()
+	r.AddValues(map[string]interface{}{
+		"port":  4242,
+		"pin":   "ab",
+		"token": "tk12345",
+		"nested": map[string]interface{}{
+			"quoted": `say "hi"`,
+		},
+	})
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"long string anywhere", "key=tk12345x", "key=[REDACTED]x"},
+		{"number as a word", "https://host:4242/api", "https://host:[REDACTED]/api"},
+		{"number inside an ID", "id a4242f and 42421", "id a4242f and 42421"},
+		{"short string as a word", "ab cab ab_c", "[REDACTED] cab ab_c"},
+		{"JSON escaped form", `"say \"hi\""`, `"[REDACTED]"`},
+		{
+			name: "caret moves with the masked text",
+			in:   "  2 | {\"url\": \"tk12345:4242\", \"x\": 1 ]\n    |                                ^",
+			want: "  2 | {\"url\": \"[REDACTED]:[REDACTED]\", \"x\": 1 ]\n    |                                         ^",
+		},
+		{
+			name: "caret inside a secret moves to its mask",
+			in:   "  3 | \t\"tk12345\"\n    | \t   ^",
+			want: "  3 | \t\"[REDACTED]\"\n    | \t ^",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
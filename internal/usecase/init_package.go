@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"context"
 	"fmt"
 	"github.com/colonyos/cpm/pkg/domain"
 )
@@ -15,7 +16,7 @@ func NewInitPackageUseCase(pkgService domain.PackageService) *InitPackageUseCase
 	}
 }
 
-func (u *InitPackageUseCase) Execute(name string) error {
+func (u *InitPackageUseCase) Execute(ctx context.Context, name string) error {
 	if name == "" {
 		return fmt.Errorf("package name cannot be empty")
 	}
@@ -1,150 +1,269 @@
 package usecase
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"time"
 
-	"github.com/colonyos/cpm/internal/engine"
 	"github.com/colonyos/cpm/pkg/domain"
 )
 
+// InstallOptions holds the user supplied settings for an install.
+type InstallOptions struct {
+	RenderOptions
+	// DryRun prints the rendered specs instead of submitting them
+	DryRun bool
+	// Atomic removes the resources already created if a spec fails to
+	// submit, or a workflow fails while waiting, so a failed install
+	// leaves nothing behind in the colony
+	Atomic bool
+	// Wait polls the submitted workflows until they finish and fails the
+	// install if any of them fails
+	Wait bool
+	// PollInterval is how often Wait polls, DefaultPollInterval if zero
+	PollInterval time.Duration
+}
+
+// rollbackTimeout bounds the removal of resources after a failed atomic
+// install, which may run after the install's own context is done.
+const rollbackTimeout = 30 * time.Second
+
 type InstallPackageUseCase struct {
-	pkgService      domain.PackageService
-	renderer        domain.TemplateEngine
-	submitter       domain.Submitter
-	stateService    domain.StateService
-	registryService domain.RegistryService
+	packageRenderer
+	submitter    domain.Submitter
+	stateService domain.StateService
 }
 
-func NewInstallPackageUseCase(pkgService domain.PackageService, renderer domain.TemplateEngine, submitter domain.Submitter, stateService domain.StateService, registryService domain.RegistryService) *InstallPackageUseCase {
+func NewInstallPackageUseCase(pkgService domain.PackageService, renderer domain.TemplateEngine, submitter domain.Submitter, stateService domain.StateService, registryService domain.RegistryService, decrypter domain.SecretDecrypter) *InstallPackageUseCase {
 	return &InstallPackageUseCase{
-		pkgService:      pkgService,
-		renderer:        renderer,
-		submitter:       submitter,
-		stateService:    stateService,
-		registryService: registryService,
+		packageRenderer: packageRenderer{
+			pkgService:      pkgService,
+			renderer:        renderer,
+			registryService: registryService,
+			decrypter:       decrypter,
+		},
+		submitter:    submitter,
+		stateService: stateService,
 	}
 }
 
-func (u *InstallPackageUseCase) Execute(path string, setValues map[string]interface{}, version string) error {
-	// 0. Prepare workPath (handle archive vs directory vs registry fetch)
-	workPath := path
-	_, err := os.Stat(path)
-
-	if os.IsNotExist(err) {
-		// Not found locally? Try fetching from registry
-		// Assumption: path is the package name
-		fmt.Printf("Package %s not found locally, attempting fetch from registry...\n", path)
-		if version == "" {
-			return fmt.Errorf("version is required when installing from registry")
+// Execute renders and submits the package. If a submission fails, or ctx
+// is cancelled or times out, the specs not yet submitted are abandoned and
+// the release is recorded as failed with the resources created so far. In
+// atomic mode those resources are removed first. With Wait a workflow that fails, or does not finish before ctx
+// is done, fails the install too.
+func (u *InstallPackageUseCase) Execute(ctx context.Context, path string, opts InstallOptions) error {
+	// 1. Resolve, merge values and render
+	pkg, err := u.render(ctx, path, opts.RenderOptions)
+	if err != nil {
+		return err
+	}
+
+	var reader domain.StatusReader
+	if opts.Wait && !opts.DryRun {
+		var ok bool
+		if reader, ok = u.submitter.(domain.StatusReader); !ok {
+			return fmt.Errorf("--wait is not supported by this colony client")
 		}
+	}
 
-		artifactPath, err := u.registryService.Fetch(path, version)
+	if opts.DryRun {
+		out, err := json.MarshalIndent(pkg.redactor.RedactSpecs(pkg.specs), "", "  ")
 		if err != nil {
-			return fmt.Errorf("failed to fetch from registry: %w", err)
+			return fmt.Errorf("failed to encode specs: %w", err)
 		}
-		// artifactPath is a temp file probably, we need to handle it same as local archive
-		path = artifactPath
-		// Fallthrough to archive handling
+		fmt.Println(string(out))
+		return nil
 	}
 
-	info, err := os.Stat(path)
+	// 2. Determine the release Name and revision before submitting, since
+	// they are part of each spec's idempotency key.
+	// Priority: --set name > values.yaml name > manifest name (not loaded here efficiently yet) > directory name
+	// For now, let's use the 'name' from the last spec or a default.
+	var lastColonyID string
+	var lastName string
+	for _, spec := range pkg.specs {
+		if n, ok := spec["name"].(string); ok {
+			lastName = n
+		}
+		if c, ok := spec["colonyId"].(string); ok {
+			lastColonyID = c
+		}
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to access path: %w", err)
+	releaseName := "unknown"
+	if nameOverride, ok := opts.Values["name"].(string); ok {
+		releaseName = nameOverride
+	} else if lastName != "" {
+		releaseName = lastName
 	}
 
-	if !info.IsDir() {
-		// It's a file, assume it's an archive
-		tempDir, err := os.MkdirTemp("", "cpm-install-*")
-		if err != nil {
-			return fmt.Errorf("failed to create temp dir: %w", err)
+	revision := 1
+	installID := newInstallID()
+	if prev, err := u.stateService.Get(ctx, releaseName); err == nil {
+		revision = prev.Revision + 1
+		if resumable(prev) {
+			revision = prev.Revision
+			installID = prev.InstallID
 		}
-		defer os.RemoveAll(tempDir) // Clean up
+	}
 
-		if err := u.pkgService.Unpack(path, tempDir); err != nil {
-			return fmt.Errorf("failed to unpack archive: %w", err)
-		}
-		workPath = tempDir
+	release := &domain.Release{
+		Name:        releaseName, // This logic needs to be more robust
+		Version:     "0.1.0",     // Placeholder, need manifest load
+		Revision:    revision,
+		InstallID:   installID,
+		ColonyID:    lastColonyID,
+		InstallTime: time.Now(),
+		Values:      pkg.redactor.RedactValues(pkg.values),
+		Status:      domain.ReleaseDeployed,
 	}
 
-	// 1. Load Defaults
-	values, err := engine.LoadValues(workPath)
-	if err != nil {
-		return fmt.Errorf("failed to load values: %w", err)
+	// 3. Submit each spec, recording what it created in the colony
+	for i, spec := range pkg.specs {
+		resource, err := u.submit(ctx, spec, release)
+		if err != nil {
+			// Record what was created so far, so status and uninstall
+			// can still find it
+			return u.fail(ctx, release, pkg.redactor.RedactError(err), opts.Atomic)
+		}
+		resource.Template = pkg.source(i)
+		release.Resources = append(release.Resources, resource)
 	}
 
-	// 2. Override with --set flags (TODO: Merge logic)
-	for k, v := range setValues {
-		values[k] = v // Simple top-level override for MVP
+	// 4. Wait for the workflows to finish
+	if reader != nil {
+		if err := waitForWorkflows(ctx, reader, release.Resources, opts.PollInterval); err != nil {
+			return u.fail(ctx, release, pkg.redactor.RedactError(err), opts.Atomic)
+		}
 	}
 
-	// 3. Render Templates
-	renderedBytes, err := u.renderer.Render(workPath, values)
+	// 5. Save State
+	// Version? We didn't parse manifest here explicitly in step 1.
+	// Improvement: Load Manifest in Step 1.
+
+	// Everything was submitted, so record the release even if ctx ends now
+	err = u.stateService.Save(context.WithoutCancel(ctx), release)
 	if err != nil {
-		return fmt.Errorf("render failed: %w", err)
+		fmt.Printf("Warning: failed to save state: %v\n", err)
 	}
 
-	// 4. Parse rendered output (Assuming JSON Array of objects)
-	// We mocked Render to return "[{...},{...}]"
-	var specs []map[string]interface{}
-	if err := json.Unmarshal(renderedBytes, &specs); err != nil {
-		// If unmarshal fails as array, try single object? Or maybe it's invalid JSON
-		return fmt.Errorf("failed to parse rendered templates as JSON: %w\nOutput: %s", err, string(renderedBytes))
-	}
+	return nil
+}
 
-	// 5. Submit each spec
-	var lastColonyID string
-	var lastName string
+// submit registers a function spec or submits any other spec as a workflow,
+// and returns the resource created for it.
+func (u *InstallPackageUseCase) submit(ctx context.Context, spec map[string]interface{}, release *domain.Release) (domain.Resource, error) {
+	jsonBytes, _ := json.MarshalIndent(spec, "", "  ")
+	key := idempotencyKey(release.Name, release.Revision, release.InstallID, jsonBytes)
+	resource := domain.Resource{
+		Kind: domain.SpecKind(spec),
+		Name: domain.SpecName(spec),
+	}
 
-	for _, spec := range specs {
-		// Detect type (Executor vs Workflow) - logic needed
-		// For now, just submit as workflow if it looks like one, or print.
+	if resource.Kind == domain.KindFunction {
+		functionID, err := u.submitter.RegisterFunction(ctx, jsonBytes, key)
+		if err != nil {
+			return resource, err
+		}
+		fmt.Printf("Registered function %s (%s)\n", resource.Name, functionID)
+		resource.ID = functionID
+		return resource, nil
+	}
 
-		jsonBytes, _ := json.MarshalIndent(spec, "", "  ")
+	graphID, err := u.submitter.SubmitWorkflow(ctx, jsonBytes, key)
+	if err != nil {
+		return resource, err
+	}
+	resource.ID = graphID
+	return resource, nil
+}
 
-		// Capture basic info for state
-		if n, ok := spec["name"].(string); ok {
-			lastName = n
+// resumable reports whether a re-run of the release should reuse its
+// revision, so the colony skips the specs it already accepted. That is
+// the case for an install that failed while submitting, but not after a
+// rollback removed the specs or after --wait saw them run.
+func resumable(prev *domain.Release) bool {
+	if prev.Status != domain.ReleaseFailed || prev.RolledBack {
+		return false
+	}
+	for _, r := range prev.Resources {
+		if r.State != "" {
+			return false
 		}
-		if c, ok := spec["colonyId"].(string); ok {
-			lastColonyID = c
+	}
+	return true
+}
+
+// fail records the release as failed with err as the reason, after
+// rolling back its resources in atomic mode, and returns the error.
+func (u *InstallPackageUseCase) fail(ctx context.Context, release *domain.Release, err error, atomic bool) error {
+	if atomic {
+		if rbErr := u.rollback(ctx, release); rbErr != nil {
+			err = fmt.Errorf("%w; rollback failed: %v", err, rbErr)
 		}
+	}
+	u.recordFailure(ctx, release, err)
+	return err
+}
 
-		// Heuristic to guess type?
-		// Real implementation would look for "conditions" key for Executors or "process" for Workflows.
+// rollback removes the resources of the release in reverse order of
+// creation. Resources that cannot be removed are kept in the release, so
+// they can be cleaned up later.
+func (u *InstallPackageUseCase) rollback(ctx context.Context, release *domain.Release) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
 
-		err := u.submitter.SubmitWorkflow(jsonBytes)
+	var remaining []domain.Resource
+	var errs []error
+	for i := len(release.Resources) - 1; i >= 0; i-- {
+		r := release.Resources[i]
+		var err error
+		if r.Kind == domain.KindFunction {
+			err = u.submitter.RemoveFunction(ctx, r.ID)
+		} else {
+			err = u.submitter.RemoveWorkflow(ctx, r.ID)
+		}
 		if err != nil {
-			return err
+			errs = append(errs, err)
+			remaining = append([]domain.Resource{r}, remaining...)
+			continue
 		}
+		fmt.Printf("Rolled back %s %s (%s)\n", r.Kind, r.Name, r.ID)
 	}
 
-	// 6. Save State
-	// We need to determine the release Name.
-	// Priority: --set name > values.yaml name > manifest name (not loaded here efficiently yet) > directory name
-	// For now, let's use the 'name' from the last submitted spec or a default.
-	releaseName := "unknown"
-	if nameOverride, ok := setValues["name"].(string); ok {
-		releaseName = nameOverride
-	} else if lastName != "" {
-		releaseName = lastName
-	}
-
-	// Version? We didn't parse manifest here explicitly in step 1-3.
-	// Improvement: Load Manifest in Step 1.
+	release.Resources = remaining
+	release.RolledBack = true
+	return errors.Join(errs...)
+}
 
-	err = u.stateService.Save(&domain.Release{
-		Name:        releaseName, // This logic needs to be more robust
-		Version:     "0.1.0",     // Placeholder, need manifest load
-		ColonyID:    lastColonyID,
-		InstallTime: time.Now(),
-	})
-	if err != nil {
+// recordFailure saves the release as failed, with the resources created
+// so far. ctx may already be done, so the state is saved without its
+// cancellation.
+func (u *InstallPackageUseCase) recordFailure(ctx context.Context, release *domain.Release, reason error) {
+	release.Status = domain.ReleaseFailed
+	release.Reason = reason.Error()
+	if err := u.stateService.Save(context.WithoutCancel(ctx), release); err != nil {
 		fmt.Printf("Warning: failed to save state: %v\n", err)
 	}
+}
 
-	return nil
+// idempotencyKey identifies the submission of a spec in an install: the
+// hex SHA-256 of the release name, revision, install ID and spec digest.
+func idempotencyKey(release string, revision int, installID string, spec []byte) string {
+	digest := sha256.Sum256(spec)
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s\x00%x", release, revision, installID, digest)))
+	return hex.EncodeToString(key[:])
+}
+
+// newInstallID returns a random 128-bit hex install ID.
+func newInstallID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
 }
@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/patch"
+	"github.com/colonyos/cpm/internal/secrets"
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+const (
+	LintError   = "ERROR"
+	LintWarning = "WARNING"
+)
+
+// LintMessage is a single finding reported by the linter.
+type LintMessage struct {
+	Severity string
+	Path     string
+	Message  string
+}
+
+func (m LintMessage) String() string {
+	return fmt.Sprintf("[%s] %s: %s", m.Severity, m.Path, m.Message)
+}
+
+var envNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+type LintPackageUseCase struct {
+	pkgService domain.PackageService
+	renderer   domain.TemplateEngine
+}
+
+func NewLintPackageUseCase(pkgService domain.PackageService, renderer domain.TemplateEngine) *LintPackageUseCase {
+	return &LintPackageUseCase{
+		pkgService: pkgService,
+		renderer:   renderer,
+	}
+}
+
+// Execute checks the package at path and returns the findings. The error
+// is only set if the package could not be inspected at all.
+func (u *LintPackageUseCase) Execute(ctx context.Context, path string) ([]LintMessage, error) {
+	pkgFS, err := u.pkgService.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	var msgs []LintMessage
+	add := func(severity, path, format string, args ...interface{}) {
+		msgs = append(msgs, LintMessage{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// 1. Manifest
+	manifest, err := u.pkgService.LoadManifestFS(pkgFS)
+	if err != nil {
+		add(LintError, "colony.yaml", "%v", err)
+	} else {
+		if manifest.Name == "" || manifest.Version == "" {
+			add(LintError, "colony.yaml", "manifest must have name and version")
+		}
+		for _, name := range manifest.TemplateFunctions {
+			if !engine.IsRestrictedFunction(name) {
+				add(LintWarning, "colony.yaml", "templateFunctions: %q is not a restricted function and does not need to be declared", name)
+			}
+		}
+	}
+
+	// 2. JSON templates must escape values inserted into string literals
+	err = fs.WalkDir(pkgFS, "templates", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !engine.IsJSONTemplate(name) {
+			return err
+		}
+		source, err := fs.ReadFile(pkgFS, name)
+		if err != nil {
+			return err
+		}
+		for _, in := range engine.UnescapedInterpolations(string(source)) {
+			add(LintWarning, fmt.Sprintf("%s:%d", name, in.Line), "%s is inserted into a JSON string without escaping; use toJsonString, or quoteJson without the surrounding quotes", in.Action)
+		}
+		return nil
+	})
+	if err != nil {
+		add(LintError, "templates", "%v", err)
+	}
+
+	// 3. Default values must load and render
+	defaults, err := engine.LoadValuesFS(pkgFS, "")
+	if err != nil {
+		add(LintError, "values.yaml", "%v", err)
+		return msgs, nil
+	}
+	for _, key := range secretLookingKeys(defaults, "") {
+		add(LintWarning, "values.yaml", "%q looks like a secret, move it to an encrypted %s", key, secrets.FileName)
+	}
+
+	// Encrypted secrets are rendered as masked placeholders so the
+	// templates can be checked without the key
+	var masked map[string]interface{}
+	if _, err := fs.Stat(pkgFS, secrets.FileName); err == nil {
+		encrypted, err := engine.ReadValuesFS(pkgFS, secrets.FileName)
+		if err != nil {
+			add(LintError, secrets.FileName, "%v", err)
+		} else {
+			for _, p := range secrets.PlaintextPaths(encrypted) {
+				add(LintError, secrets.FileName, "value %q is not encrypted (run 'cpm secrets encrypt')", p)
+			}
+			masked = secrets.MaskEncrypted(encrypted).(map[string]interface{})
+		}
+	}
+
+	if err := u.render(ctx, pkgFS, engine.CoalesceValues(copyValues(defaults), masked)); err != nil {
+		add(LintError, "templates", "%v", err)
+	}
+
+	// 4. Each environment profile must load, only override known keys and render
+	envs, err := engine.ListEnvironmentsFS(pkgFS)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range envs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file := engine.EnvValuesFile(env)
+		if !envNamePattern.MatchString(env) {
+			add(LintWarning, file, "environment name %q should be lowercase alphanumerics and dashes", env)
+		}
+
+		values, err := engine.LoadValuesFS(pkgFS, env)
+		if err != nil {
+			add(LintError, file, "%v", err)
+			continue
+		}
+
+		overlay, err := engine.ReadValuesFS(pkgFS, file)
+		if err == nil {
+			delete(overlay, engine.PatchesKey)
+			for _, key := range unknownKeys(defaults, overlay) {
+				add(LintWarning, file, "key %q is not defined in values.yaml", key)
+			}
+		}
+
+		if _, err := patch.LoadFS(pkgFS, file); err != nil {
+			add(LintError, file, "%v", err)
+		}
+
+		if err := u.render(ctx, pkgFS, engine.CoalesceValues(values, masked)); err != nil {
+			add(LintError, file, "templates fail to render: %v", err)
+		}
+	}
+
+	return msgs, nil
+}
+
+func (u *LintPackageUseCase) render(ctx context.Context, pkgFS fs.FS, values map[string]interface{}) error {
+	rendered, err := u.renderer.RenderFS(ctx, pkgFS, values)
+	if err != nil {
+		return err
+	}
+	var specs []map[string]interface{}
+	if err := json.Unmarshal(rendered, &specs); err != nil {
+		return fmt.Errorf("rendered output is not valid JSON: %w", err)
+	}
+	return nil
+}
+
+// secretKeyPattern matches value names that usually hold credentials.
+var secretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|apikey|api_key|privatekey|prvkey)`)
+
+// secretLookingKeys returns the dotted paths of non-empty string values in
+// values whose key looks like it holds a credential.
+func secretLookingKeys(values map[string]interface{}, prefix string) []string {
+	var keys []string
+	for k, v := range values {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		switch t := v.(type) {
+		case map[string]interface{}:
+			keys = append(keys, secretLookingKeys(t, path)...)
+		case string:
+			if t != "" && secretKeyPattern.MatchString(k) {
+				keys = append(keys, path)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// copyValues returns a deep copy of the maps in values.
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = copyValues(m)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// unknownKeys returns the top-level keys of overlay that defaults lacks.
+func unknownKeys(defaults, overlay map[string]interface{}) []string {
+	var keys []string
+	for k := range overlay {
+		if _, ok := defaults[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,10 @@
 package usecase
 
-import "github.com/colonyos/cpm/pkg/domain"
+import (
+	"context"
+
+	"github.com/colonyos/cpm/pkg/domain"
+)
 
 type ListPackagesUseCase struct {
 	stateService domain.StateService
@@ -12,6 +16,6 @@ func NewListPackagesUseCase(stateService domain.StateService) *ListPackagesUseCa
 	}
 }
 
-func (u *ListPackagesUseCase) Execute() ([]*domain.Release, error) {
-	return u.stateService.List()
+func (u *ListPackagesUseCase) Execute(ctx context.Context) ([]*domain.Release, error) {
+	return u.stateService.List(ctx)
 }
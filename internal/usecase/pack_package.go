@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/colonyos/cpm/pkg/domain"
@@ -16,7 +17,7 @@ func NewPackPackageUseCase(pkgService domain.PackageService) *PackPackageUseCase
 	}
 }
 
-func (u *PackPackageUseCase) Execute(path string) error {
+func (u *PackPackageUseCase) Execute(ctx context.Context, path string) error {
 	// 1. Load Manifest to get version and name
 	manifest, err := u.pkgService.LoadManifest(path)
 	if err != nil {
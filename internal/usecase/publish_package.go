@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -19,7 +20,7 @@ func NewPublishPackageUseCase(pkgService domain.PackageService, registryService
 	}
 }
 
-func (u *PublishPackageUseCase) Execute(path string) error {
+func (u *PublishPackageUseCase) Execute(ctx context.Context, path string) error {
 	// 1. Pack the package first to ensure we have a fresh artifact
 	// Need to load manifest to get name/version
 	manifest, err := u.pkgService.LoadManifest(path)
@@ -37,7 +38,7 @@ func (u *PublishPackageUseCase) Execute(path string) error {
 	// Detailed implementation: Pack returns path to created file.
 
 	// 2. Publish to registry
-	if err := u.registryService.Publish(artifactPath); err != nil {
+	if err := u.registryService.Publish(ctx, artifactPath); err != nil {
 		return fmt.Errorf("failed to publish package: %w", err)
 	}
 
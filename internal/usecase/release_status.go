@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// Resource states reported by status besides the process states
+const (
+	// ResourceRegistered is the state of a function that exists
+	ResourceRegistered = "registered"
+	// ResourceMissing is the state of a resource that no longer exists in
+	// the colony
+	ResourceMissing = "missing"
+)
+
+// ReleaseStatus is the live state of a release's resources in the colony.
+type ReleaseStatus struct {
+	Name      string           `json:"name"`
+	Version   string           `json:"version"`
+	Revision  int              `json:"revision"`
+	Status    string           `json:"status"`
+	Resources []ResourceStatus `json:"resources"`
+}
+
+// ResourceStatus is the live state of a resource created by a release.
+type ResourceStatus struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	ID    string `json:"id"`
+	State string `json:"state"`
+	// ExecutorID is the executor that offers a function
+	ExecutorID string `json:"executorId,omitempty"`
+	// Processes are the processes of a workflow
+	Processes []domain.Process `json:"processes,omitempty"`
+}
+
+type ReleaseStatusUseCase struct {
+	stateService domain.StateService
+	reader       domain.StatusReader
+}
+
+func NewReleaseStatusUseCase(stateService domain.StateService, reader domain.StatusReader) *ReleaseStatusUseCase {
+	return &ReleaseStatusUseCase{
+		stateService: stateService,
+		reader:       reader,
+	}
+}
+
+// Execute queries the colony for each resource recorded in the release.
+// Resources that no longer exist are reported as missing.
+func (u *ReleaseStatusUseCase) Execute(ctx context.Context, name string) (*ReleaseStatus, error) {
+	release, err := u.stateService.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ReleaseStatus{
+		Name:      release.Name,
+		Version:   release.Version,
+		Revision:  release.Revision,
+		Status:    release.Status,
+		Resources: []ResourceStatus{},
+	}
+	if status.Status == "" {
+		status.Status = domain.ReleaseDeployed
+	}
+
+	for _, r := range release.Resources {
+		rs := ResourceStatus{Kind: r.Kind, Name: r.Name, ID: r.ID}
+		if err := u.query(ctx, &rs); err != nil {
+			var notFound *domain.NotFoundError
+			if !errors.As(err, &notFound) {
+				return nil, err
+			}
+			rs.State = ResourceMissing
+		}
+		status.Resources = append(status.Resources, rs)
+	}
+	return status, nil
+}
+
+// query fills in the state of rs from the colony.
+func (u *ReleaseStatusUseCase) query(ctx context.Context, rs *ResourceStatus) error {
+	if rs.Kind == domain.KindFunction {
+		function, err := u.reader.GetFunction(ctx, rs.ID)
+		if err != nil {
+			return err
+		}
+		rs.State = ResourceRegistered
+		rs.ExecutorID = function.ExecutorID
+		return nil
+	}
+
+	// Any other spec was submitted as a workflow
+	graph, err := u.reader.GetProcessGraph(ctx, rs.ID)
+	if err != nil {
+		return err
+	}
+	rs.State = graph.State
+	rs.Processes = graph.Processes
+	return nil
+}
@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/patch"
+	"github.com/colonyos/cpm/internal/secrets"
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// RenderOptions holds the user supplied settings for rendering a package.
+type RenderOptions struct {
+	// Version is required when the package is fetched from the registry
+	Version string
+	// Env selects the values-<env>.yaml profile to merge over values.yaml
+	Env string
+	// DefaultEnv is used when Env is empty and the package defines it
+	DefaultEnv string
+	// Values are the user overrides from values files and --set flags
+	Values map[string]interface{}
+	// Sandbox forces sandbox mode for local packages. Packages fetched
+	// from the registry are always rendered in sandbox mode.
+	Sandbox bool
+	// AllowedFunctions approves restricted template functions declared in
+	// the package manifest
+	AllowedFunctions []string
+	// Patches are files of post-render patches, applied after those of the
+	// environment profile
+	Patches []string
+	// PostRenderer, if set, transforms the specs after patching
+	PostRenderer domain.PostRenderer
+}
+
+// renderedPackage is the result of rendering a package with its values.
+type renderedPackage struct {
+	specs []map[string]interface{}
+	// sources are the template files of the specs, nil if unknown
+	sources []string
+	values  map[string]interface{}
+	// redactor masks the package secrets in any output derived from it
+	redactor *secrets.Redactor
+}
+
+// packageRenderer resolves a package, merges its values and renders it.
+// It is shared by the install and template use cases.
+type packageRenderer struct {
+	pkgService      domain.PackageService
+	renderer        domain.TemplateEngine
+	registryService domain.RegistryService
+	decrypter       domain.SecretDecrypter
+}
+
+func (r *packageRenderer) render(ctx context.Context, path string, opts RenderOptions) (*renderedPackage, error) {
+	// 0. Prepare workPath (handle archive vs directory vs registry fetch)
+	sandbox := opts.Sandbox
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// Not found locally? Try fetching from registry
+		// Assumption: path is the package name
+		// Progress goes to stderr, so cpm template output can still be piped
+		fmt.Fprintf(os.Stderr, "Package %s not found locally, attempting fetch from registry...\n", path)
+		if opts.Version == "" {
+			return nil, fmt.Errorf("version is required when installing from registry")
+		}
+
+		artifactPath, err := r.registryService.Fetch(ctx, path, opts.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch from registry: %w", err)
+		}
+		// artifactPath is a temp file, handle it same as local archive
+		path = artifactPath
+		sandbox = true
+	}
+
+	// Archives are read in memory, never unpacked to disk
+	pkgFS, err := r.pkgService.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	// 1. Load Defaults, with the environment profile merged on top
+	env := opts.Env
+	if env == "" && opts.DefaultEnv != "" && engine.HasEnvironmentFS(pkgFS, opts.DefaultEnv) {
+		env = opts.DefaultEnv
+	}
+	values, err := engine.LoadValuesFS(pkgFS, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load values: %w", err)
+	}
+
+	// 2. Decrypt secrets.yaml in memory and merge it over the defaults
+	redactor := secrets.NewRedactor()
+	if _, err := fs.Stat(pkgFS, secrets.FileName); err == nil {
+		encrypted, err := engine.ReadValuesFS(pkgFS, secrets.FileName)
+		if err != nil {
+			return nil, err
+		}
+		if r.decrypter == nil {
+			return nil, fmt.Errorf("package has a %s but no secrets key is configured", secrets.FileName)
+		}
+		decrypted, err := r.decrypter.DecryptValues(encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", secrets.FileName, err)
+		}
+		redactor.AddValues(decrypted)
+		values = engine.CoalesceValues(values, decrypted)
+	}
+
+	// 3. Deep-merge user overrides (values files and --set flags)
+	values = engine.CoalesceValues(values, opts.Values)
+
+	// 4. Render Templates, restricting the function set for untrusted packages
+	renderer := r.renderer
+	if sandbox {
+		allowed, err := r.allowedFunctions(pkgFS, opts.AllowedFunctions)
+		if err != nil {
+			return nil, err
+		}
+		renderer = renderer.Sandboxed(allowed)
+	}
+
+	renderedBytes, sources, err := renderer.RenderFSWithSources(ctx, pkgFS, values)
+	if err != nil {
+		return nil, redactor.RedactError(fmt.Errorf("render failed: %w", err))
+	}
+
+	// 5. Parse rendered output (Assuming JSON Array of objects)
+	var specs []map[string]interface{}
+	if err := json.Unmarshal(renderedBytes, &specs); err != nil {
+		return nil, redactor.RedactError(fmt.Errorf("failed to parse rendered templates as JSON: %w", err))
+	}
+
+	// 6. Apply the patches of the environment profile, then the --patch files
+	var patches []patch.Patch
+	if env != "" {
+		p, err := patch.LoadFS(pkgFS, engine.EnvValuesFile(env))
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, p...)
+	}
+	for _, file := range opts.Patches {
+		p, err := patch.Load(file)
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, p...)
+	}
+	specs, err = patch.Apply(specs, patches)
+	if err != nil {
+		return nil, redactor.RedactError(fmt.Errorf("failed to apply patches: %w", err))
+	}
+
+	// 7. Pipe the specs through the post-renderer, whose output replaces them
+	if opts.PostRenderer != nil {
+		in, err := json.Marshal(specs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode specs: %w", err)
+		}
+		out, err := opts.PostRenderer.Run(ctx, in)
+		if err != nil {
+			return nil, redactor.RedactError(err)
+		}
+		specs = nil
+		if err := json.Unmarshal(out, &specs); err != nil {
+			return nil, redactor.RedactError(fmt.Errorf("post-renderer output is not a JSON array of specs: %w", err))
+		}
+		// The post-renderer may add, drop or reorder specs
+		sources = nil
+	}
+
+	return &renderedPackage{
+		specs:    specs,
+		sources:  sources,
+		values:   values,
+		redactor: redactor,
+	}, nil
+}
+
+// source returns the template file of spec i, or "" if it is not known.
+func (p *renderedPackage) source(i int) string {
+	if i < len(p.sources) {
+		return p.sources[i]
+	}
+	return ""
+}
+
+// allowedFunctions checks the restricted functions declared in the manifest
+// against those approved by the user and returns the ones to enable.
+func (r *packageRenderer) allowedFunctions(pkgFS fs.FS, approved []string) ([]string, error) {
+	manifest, err := r.pkgService.LoadManifestFS(pkgFS)
+	if err != nil {
+		// Without a manifest nothing is declared, so nothing is allowed
+		return nil, nil
+	}
+
+	isApproved := make(map[string]bool)
+	for _, name := range approved {
+		isApproved[name] = true
+	}
+
+	var allowed, missing []string
+	for _, name := range manifest.TemplateFunctions {
+		if !engine.IsRestrictedFunction(name) {
+			continue
+		}
+		if isApproved[name] {
+			allowed = append(allowed, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("package requests restricted template functions %s; re-run with --allow-functions=%s to approve them",
+			strings.Join(missing, ", "), strings.Join(missing, ","))
+	}
+	return allowed, nil
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+type ShowValuesUseCase struct {
+	pkgService domain.PackageService
+}
+
+func NewShowValuesUseCase(pkgService domain.PackageService) *ShowValuesUseCase {
+	return &ShowValuesUseCase{
+		pkgService: pkgService,
+	}
+}
+
+// Execute returns the package values, with the values-<env>.yaml profile
+// merged on top if env is set.
+func (u *ShowValuesUseCase) Execute(ctx context.Context, path string, env string) (map[string]interface{}, error) {
+	pkgFS, err := u.pkgService.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	values, err := engine.LoadValuesFS(pkgFS, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load values: %w", err)
+	}
+	return values, nil
+}
+
+// Environments returns the environment profiles defined by the package.
+func (u *ShowValuesUseCase) Environments(path string) ([]string, error) {
+	pkgFS, err := u.pkgService.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	return engine.ListEnvironmentsFS(pkgFS)
+}
@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// TemplateOptions holds the user supplied settings for cpm template.
+type TemplateOptions struct {
+	RenderOptions
+	// ShowSecrets disables redaction of secret values in the output
+	ShowSecrets bool
+}
+
+type TemplatePackageUseCase struct {
+	packageRenderer
+}
+
+func NewTemplatePackageUseCase(pkgService domain.PackageService, renderer domain.TemplateEngine, registryService domain.RegistryService, decrypter domain.SecretDecrypter) *TemplatePackageUseCase {
+	return &TemplatePackageUseCase{
+		packageRenderer: packageRenderer{
+			pkgService:      pkgService,
+			renderer:        renderer,
+			registryService: registryService,
+			decrypter:       decrypter,
+		},
+	}
+}
+
+// Execute renders the package locally and returns the specs as indented
+// JSON, without submitting anything.
+func (u *TemplatePackageUseCase) Execute(ctx context.Context, path string, opts TemplateOptions) ([]byte, error) {
+	pkg, err := u.render(ctx, path, opts.RenderOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := pkg.specs
+	if !opts.ShowSecrets {
+		specs = pkg.redactor.RedactSpecs(specs)
+	}
+	out, err := json.MarshalIndent(specs, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode specs: %w", err)
+	}
+	return out, nil
+}
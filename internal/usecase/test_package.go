@@ -0,0 +1,193 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/pkgtest"
+	"github.com/colonyos/cpm/internal/secrets"
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// TestOptions controls snapshot testing for cpm test.
+type TestOptions struct {
+	// Snapshot compares the rendered output with tests/__snapshots__,
+	// writing snapshots that do not exist yet
+	Snapshot bool
+	// UpdateSnapshots overwrites snapshots that differ. It implies Snapshot.
+	UpdateSnapshots bool
+}
+
+type TestPackageUseCase struct {
+	pkgService domain.PackageService
+	renderer   domain.TemplateEngine
+}
+
+func NewTestPackageUseCase(pkgService domain.PackageService, renderer domain.TemplateEngine) *TestPackageUseCase {
+	return &TestPackageUseCase{
+		pkgService: pkgService,
+		renderer:   renderer,
+	}
+}
+
+// Execute runs every test suite in the package tests/ directory and returns
+// one result per test case.
+func (u *TestPackageUseCase) Execute(ctx context.Context, path string, opts TestOptions) ([]pkgtest.Result, error) {
+	if opts.UpdateSnapshots {
+		opts.Snapshot = true
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access path: %w", err)
+	}
+	if opts.Snapshot && !info.IsDir() {
+		return nil, fmt.Errorf("snapshot testing needs a package directory, not an archive")
+	}
+
+	// Archives are read in memory, never unpacked to disk
+	pkgFS, err := u.pkgService.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	suites, err := pkgtest.LoadSuitesFS(pkgFS)
+	if err != nil {
+		return nil, err
+	}
+	if len(suites) == 0 {
+		return nil, fmt.Errorf("no test suites found in %s", filepath.Join(path, pkgtest.Dir))
+	}
+
+	// Tests must run without the secrets key, so encrypted values are
+	// replaced by a placeholder
+	var masked map[string]interface{}
+	if _, err := fs.Stat(pkgFS, secrets.FileName); err == nil {
+		encrypted, err := engine.ReadValuesFS(pkgFS, secrets.FileName)
+		if err != nil {
+			return nil, err
+		}
+		masked = secrets.MaskEncrypted(encrypted).(map[string]interface{})
+	}
+
+	var results []pkgtest.Result
+	for _, suite := range suites {
+		var snapshots *pkgtest.Snapshots
+		if opts.Snapshot {
+			snapshots, err = pkgtest.LoadSnapshots(path, suite)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, tc := range suite.Tests {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			start := time.Now()
+			result := u.runCase(ctx, pkgFS, suite, tc, masked, snapshots, opts.UpdateSnapshots)
+			result.Duration = time.Since(start)
+			results = append(results, result)
+		}
+
+		if snapshots != nil {
+			if opts.UpdateSnapshots {
+				snapshots.Prune(suite)
+			}
+			if err := snapshots.Save(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
+
+// runCase renders the package with the case values, checks the assertions
+// and, if snapshots is set, compares the output with the stored snapshot.
+func (u *TestPackageUseCase) runCase(ctx context.Context, pkgFS fs.FS, suite *pkgtest.Suite, tc pkgtest.Case, masked map[string]interface{}, snapshots *pkgtest.Snapshots, update bool) pkgtest.Result {
+	result := pkgtest.Result{Suite: suite.Name, File: suite.File, Name: tc.Name}
+
+	values, err := u.caseValues(pkgFS, suite, tc, masked)
+	if err != nil {
+		result.Failures = []string{err.Error()}
+		return result
+	}
+
+	docs, renderErr := u.render(ctx, pkgFS, values)
+
+	expectsError := false
+	for _, a := range tc.Asserts {
+		if a.ExpectsRenderError() {
+			expectsError = true
+		}
+	}
+	if renderErr != nil && !expectsError {
+		result.Failures = []string{fmt.Sprintf("render failed: %v", renderErr)}
+		return result
+	}
+
+	for i, a := range tc.Asserts {
+		if err := a.Check(docs, renderErr); err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("assert[%d] %v", i, err))
+		}
+	}
+
+	if snapshots == nil || renderErr != nil {
+		return result
+	}
+
+	current, err := pkgtest.Render(docs)
+	if err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("snapshot: %v", err))
+		return result
+	}
+	stored, ok := snapshots.Get(tc.Name)
+	switch {
+	case !ok || (update && stored != current):
+		snapshots.Set(tc.Name, current)
+		result.SnapshotWritten = true
+	case stored != current:
+		result.Failures = append(result.Failures, "snapshot does not match (run with --update-snapshots to accept):\n"+pkgtest.Diff(stored, current))
+	}
+	return result
+}
+
+func (u *TestPackageUseCase) caseValues(pkgFS fs.FS, suite *pkgtest.Suite, tc pkgtest.Case, masked map[string]interface{}) (map[string]interface{}, error) {
+	values, err := engine.LoadValuesFS(pkgFS, tc.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load values: %w", err)
+	}
+	values = engine.CoalesceValues(values, masked)
+
+	overrides := make(map[string]interface{})
+	suiteDir := path.Dir(suite.File)
+	for _, f := range tc.Values {
+		fileValues, err := engine.ReadValuesFS(pkgFS, path.Join(suiteDir, f))
+		if err != nil {
+			return nil, err
+		}
+		overrides = engine.MergeValues(overrides, fileValues)
+	}
+	overrides = engine.MergeValues(overrides, tc.Set)
+
+	return engine.CoalesceValues(values, overrides), nil
+}
+
+func (u *TestPackageUseCase) render(ctx context.Context, pkgFS fs.FS, values map[string]interface{}) ([]interface{}, error) {
+	rendered, err := u.renderer.RenderFS(ctx, pkgFS, values)
+	if err != nil {
+		return nil, err
+	}
+	var docs []interface{}
+	if err := json.Unmarshal(rendered, &docs); err != nil {
+		return nil, fmt.Errorf("failed to parse rendered templates as JSON: %w", err)
+	}
+	return docs, nil
+}
@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/colonyos/cpm/pkg/domain"
@@ -18,28 +19,22 @@ func NewUninstallPackageUseCase(stateService domain.StateService, submitter doma
 	}
 }
 
-func (u *UninstallPackageUseCase) Execute(name string) error {
+func (u *UninstallPackageUseCase) Execute(ctx context.Context, name string) error {
 	// 1. Check if installed
-	release, err := u.stateService.Get(name)
+	release, err := u.stateService.Get(ctx, name)
 	if err != nil {
 		return err
 	}
 
 	// 2. Submit deletion to ColonyOS (if supported)
-	// For MVP, we'll just print or invoke a "delete" workflow if we had one.
-	// Since ColonyOS doesn't explicitly have "uninstall" for a random workflow without tracking IDs,
-	// we will simulate it or maybe submit a termination signal.
-	// Let's print for now via the submitter/client or just log.
-
-	// Real world: We might have saved the process ID in state and now we kill it.
-	// We only saved "ColonyID".
-
-	// Let's assume we just remove it from local state effectively "forgetting" it,
-	// or maybe we should tell the user "We removed it from CPM, but please check ColonyOS"
-	// until we have robust process tracking.
-
+	// The release records the IDs of the process graphs and functions it
+	// created. Uninstall only forgets the release, so list them for the
+	// user to clean up in ColonyOS.
 	fmt.Printf("Uninstalling package %s (ColonyID: %s)...\n", release.Name, release.ColonyID)
+	for _, r := range release.Resources {
+		fmt.Printf("  leaving %s %s (%s) in the colony\n", r.Kind, r.Name, r.ID)
+	}
 
 	// 3. Remove from state
-	return u.stateService.Delete(name)
+	return u.stateService.Delete(ctx, name)
 }
@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// DefaultPollInterval is how often --wait polls the colony.
+const DefaultPollInterval = 2 * time.Second
+
+// waitForWorkflows polls the process graphs of the resources submitted as
+// workflows, which is every kind but functions, until every process has
+// finished, printing each change of a process state. The last state of
+// each workflow is recorded in its resource. It returns an error if a
+// workflow failed or ctx is done first.
+func waitForWorkflows(ctx context.Context, reader domain.StatusReader, resources []domain.Resource, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	var pending []int
+	for i, r := range resources {
+		// Any spec but a function was submitted as a workflow
+		if r.Kind != domain.KindFunction {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	fmt.Printf("Waiting for %d workflow(s) to finish...\n", len(pending))
+
+	printed := make(map[string]string)
+	var failed []string
+	for {
+		var next []int
+		for _, i := range pending {
+			r := &resources[i]
+			graph, err := reader.GetProcessGraph(ctx, r.ID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return stopped(ctx)
+				}
+				return err
+			}
+
+			for _, p := range graph.Processes {
+				if printed[p.ID] == p.State {
+					continue
+				}
+				printed[p.ID] = p.State
+				fmt.Printf("  %s\n", describeProcess(r.Name, p))
+			}
+
+			r.State = graph.State
+			if !graph.Finished() {
+				next = append(next, i)
+			} else if graph.State == domain.ProcessFailed {
+				failed = append(failed, r.Name)
+			}
+		}
+
+		pending = next
+		if len(pending) == 0 {
+			break
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return stopped(ctx)
+		case <-timer.C:
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("workflow %s failed", strings.Join(failed, ", "))
+	}
+	fmt.Println("All workflows finished successfully.")
+	return nil
+}
+
+// stopped explains why ctx ended the wait.
+func stopped(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out waiting for workflows: %w", ctx.Err())
+	}
+	return fmt.Errorf("interrupted while waiting for workflows: %w", ctx.Err())
+}
+
+// describeProcess formats a process state for progress output, e.g.
+// "pipeline/train: running on gpu-worker".
+func describeProcess(workflow string, p domain.Process) string {
+	name := p.Name
+	if name == "" {
+		name = p.ID
+	}
+	line := fmt.Sprintf("%s/%s: %s", workflow, name, p.State)
+	if p.State == domain.ProcessRunning && p.ExecutorID != "" {
+		line += " on " + p.ExecutorID
+	}
+	if len(p.Errors) > 0 {
+		line += " (" + strings.Join(p.Errors, "; ") + ")"
+	}
+	return line
+}
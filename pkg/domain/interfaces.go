@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"context"
+	"io/fs"
+)
+
 // PackageService defines operations for managing package files on disk
 type PackageService interface {
 	// Initialize creates the package scaffolding
@@ -8,6 +13,13 @@ type PackageService interface {
 	// LoadManifest reads the colony.yaml from a path
 	LoadManifest(path string) (*ColonyManifest, error)
 
+	// LoadManifestFS reads the colony.yaml from a package rooted at fsys
+	LoadManifestFS(fsys fs.FS) (*ColonyManifest, error)
+
+	// Open returns the package at path, a directory or an archive, as a
+	// read-only file system
+	Open(path string) (fs.FS, error)
+
 	// Pack creates a compressed artifact from the package directory
 	Pack(path string, name string, version string) (string, error)
 
@@ -17,19 +29,79 @@ type PackageService interface {
 
 // RegistryService defines operations for interacting with the remote registry
 type RegistryService interface {
-	Publish(artifactPath string) error
-	Fetch(packageName, version string) (string, error)
-	Search(query string) ([]string, error)
+	Publish(ctx context.Context, artifactPath string) error
+	Fetch(ctx context.Context, packageName, version string) (string, error)
+	Search(ctx context.Context, query string) ([]string, error)
 }
 
 // TemplateEngine defines operations for rendering ColonyOS specs
 type TemplateEngine interface {
-	// Render takes any templates in the package and renders them using the values.yaml
-	Render(packagePath string, values map[string]interface{}) ([]byte, error)
+	// Render takes any templates in the package and renders them using the
+	// values.yaml. Cancelling ctx aborts colony queries made by templates.
+	Render(ctx context.Context, packagePath string, values map[string]interface{}) ([]byte, error)
+
+	// RenderFS is Render for a package rooted at fsys, e.g. an archive or an embed.FS
+	RenderFS(ctx context.Context, fsys fs.FS, values map[string]interface{}) ([]byte, error)
+
+	// RenderFSWithSources is RenderFS that also returns, for each spec in
+	// the array, the template file it was rendered from
+	RenderFSWithSources(ctx context.Context, fsys fs.FS, values map[string]interface{}) ([]byte, []string, error)
+
+	// Sandboxed returns an engine for untrusted packages that withholds
+	// functions reading the environment or producing non-deterministic output,
+	// except those in allowed
+	Sandboxed(allowed []string) TemplateEngine
+}
+
+// PostRenderer transforms the rendered specs, a JSON array, before they
+// are submitted. Cancelling ctx stops it.
+type PostRenderer interface {
+	Run(ctx context.Context, rendered []byte) ([]byte, error)
+}
+
+// SecretDecrypter decrypts the values of a package's encrypted secrets.yaml
+type SecretDecrypter interface {
+	DecryptValues(values map[string]interface{}) (map[string]interface{}, error)
+}
+
+// Resource kinds that can be looked up in a colony
+const (
+	KindFunction = "function"
+	KindExecutor = "executor"
+	KindCron     = "cron"
+	// KindWorkflow is the kind of specs that are none of the above
+	KindWorkflow = "workflow"
+)
+
+// ResourceLookup queries existing objects in the target colony by name
+type ResourceLookup interface {
+	// Lookup returns the object of the given kind and name, or an empty map
+	// if it does not exist
+	Lookup(ctx context.Context, kind, name string) (map[string]interface{}, error)
+}
+
+// StatusReader queries the state of submitted workflows and registered
+// functions. An object that does not exist yields a *NotFoundError.
+type StatusReader interface {
+	// GetProcessGraph returns the workflow with the given process graph ID
+	// and the state of each of its processes
+	GetProcessGraph(ctx context.Context, processGraphID string) (*ProcessGraph, error)
+	// GetFunction returns the function with the given function ID
+	GetFunction(ctx context.Context, functionID string) (*Function, error)
 }
 
-// Submitter defines the interface for submitting to ColonyOS
+// Submitter defines the interface for submitting to ColonyOS. The
+// idempotency key identifies a submission, so a retried request is not
+// applied twice. Cancelling ctx aborts the request.
 type Submitter interface {
-	SubmitWorkflow(specJSON []byte) error
-	RegisterFunction(specJSON []byte) error
+	// SubmitWorkflow submits a workflow spec and returns the ID of the
+	// process graph created for it
+	SubmitWorkflow(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error)
+	// RegisterFunction adds a function spec and returns its function ID
+	RegisterFunction(ctx context.Context, specJSON []byte, idempotencyKey string) (string, error)
+	// RemoveWorkflow removes a submitted workflow by its process graph ID,
+	// cancelling processes that have not finished
+	RemoveWorkflow(ctx context.Context, processGraphID string) error
+	// RemoveFunction removes a registered function by its function ID
+	RemoveFunction(ctx context.Context, functionID string) error
 }
@@ -1,13 +1,27 @@
 package domain
 
 type ColonyManifest struct {
-	APIVersion   string             `yaml:"apiVersion"`
-	Name         string             `yaml:"name"`
-	Version      string             `yaml:"version"`
-	Description  string             `yaml:"description"`
-	Maintainers  []Maintainer       `yaml:"maintainers"`
-	Dependencies []Dependency       `yaml:"dependencies"`
-	Conditions   *Conditions        `yaml:"conditions,omitempty"`
+	APIVersion   string       `yaml:"apiVersion"`
+	Name         string       `yaml:"name"`
+	Version      string       `yaml:"version"`
+	Description  string       `yaml:"description"`
+	Maintainers  []Maintainer `yaml:"maintainers"`
+	Dependencies []Dependency `yaml:"dependencies"`
+	Conditions   *Conditions  `yaml:"conditions,omitempty"`
+	// TemplateFunctions lists restricted template functions (e.g. env) the
+	// package needs when rendered in sandbox mode. The user must approve them.
+	TemplateFunctions []string `yaml:"templateFunctions,omitempty"`
+	// Templates configures individual template files
+	Templates []TemplateSpec `yaml:"templates,omitempty"`
+}
+
+// TemplateSpec configures a template file
+type TemplateSpec struct {
+	// File is the path relative to templates/
+	File string `yaml:"file"`
+	// Enabled is a condition on the values, e.g. .Values.metrics.enabled.
+	// The template is skipped when it is false.
+	Enabled string `yaml:"enabled,omitempty"`
 }
 
 type Maintainer struct {
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Process states, as reported by ColonyOS
+const (
+	ProcessWaiting    = "waiting"
+	ProcessRunning    = "running"
+	ProcessSuccessful = "successful"
+	ProcessFailed     = "failed"
+)
+
+// Process is a process of a submitted workflow.
+type Process struct {
+	ID string `json:"processId"`
+	// Name is the function the process runs
+	Name  string `json:"name,omitempty"`
+	State string `json:"state"`
+	// ExecutorID is the executor the process is assigned to, if any
+	ExecutorID string    `json:"executorId,omitempty"`
+	StartTime  time.Time `json:"startTime,omitzero"`
+	EndTime    time.Time `json:"endTime,omitzero"`
+	Errors     []string  `json:"errors,omitempty"`
+}
+
+// ProcessGraph is the state of a submitted workflow and its processes.
+type ProcessGraph struct {
+	ID        string    `json:"processGraphId"`
+	State     string    `json:"state"`
+	Processes []Process `json:"processes"`
+}
+
+// Finished reports whether the workflow has succeeded or failed.
+func (g *ProcessGraph) Finished() bool {
+	return g.State == ProcessSuccessful || g.State == ProcessFailed
+}
+
+// GraphState derives the state of a workflow from its processes: failed
+// if any process failed, successful if all succeeded, running if any is
+// running and waiting otherwise.
+func GraphState(processes []Process) string {
+	successful := 0
+	running := false
+	for _, p := range processes {
+		switch p.State {
+		case ProcessFailed:
+			return ProcessFailed
+		case ProcessSuccessful:
+			successful++
+		case ProcessRunning:
+			running = true
+		}
+	}
+	if len(processes) > 0 && successful == len(processes) {
+		return ProcessSuccessful
+	}
+	if running || successful > 0 {
+		return ProcessRunning
+	}
+	return ProcessWaiting
+}
+
+// Function is a function registered in the colony.
+type Function struct {
+	ID   string `json:"functionId"`
+	Name string `json:"funcName"`
+	// ExecutorID is the executor that offers the function
+	ExecutorID string `json:"executorId,omitempty"`
+}
+
+// NotFoundError reports an object that does not exist in the colony, e.g.
+// a workflow that was removed.
+type NotFoundError struct {
+	// Kind is the kind of object, e.g. "process graph"
+	Kind string
+	ID   string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Kind, e.ID)
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "strings"
+
+// SpecName returns the name of a rendered spec, taken from its name,
+// funcName or executorName field.
+func SpecName(spec map[string]interface{}) string {
+	for _, key := range []string{"name", "funcName", "executorName"} {
+		if n, ok := spec[key].(string); ok && n != "" {
+			return n
+		}
+	}
+	return ""
+}
+
+// SpecKind returns the kind of a rendered spec: its kind field if it has
+// one, otherwise the kind implied by its fields.
+func SpecKind(spec map[string]interface{}) string {
+	if k, ok := spec["kind"].(string); ok && k != "" {
+		return strings.ToLower(k)
+	}
+	switch {
+	case spec["cronExpression"] != nil:
+		return KindCron
+	case spec["executorType"] != nil:
+		return KindExecutor
+	case spec["funcName"] != nil:
+		return KindFunction
+	}
+	return KindWorkflow
+}
@@ -1,20 +1,65 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Release represents an installed package instance
 type Release struct {
-	Name        string    `json:"name"`
-	Version     string    `json:"version"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Revision counts the installs of the release, starting at 1
+	Revision int `json:"revision,omitempty"`
+	// InstallID is a random ID of the install, part of the idempotency
+	// keys of its submissions. A resumed install keeps it, any other gets
+	// a new one, so reinstalling after an uninstall is not mistaken for a
+	// retry.
+	InstallID   string    `json:"installId,omitempty"`
 	ColonyID    string    `json:"colonyId"`
 	InstallTime time.Time `json:"installTime"`
-	// We might add Status or Manifest copy later
+	// Values are the merged values the release was rendered with, with
+	// secrets redacted
+	Values map[string]interface{} `json:"values,omitempty"`
+	// Resources are the objects the install created in the colony
+	Resources []Resource `json:"resources,omitempty"`
+	// Status is ReleaseDeployed or ReleaseFailed. Releases saved before it
+	// was recorded have no status and count as deployed.
+	Status string `json:"status,omitempty"`
+	// Reason says why a failed release failed
+	Reason string `json:"reason,omitempty"`
+	// RolledBack is set when the resources of a failed install were
+	// removed, so a new install must not reuse its revision
+	RolledBack bool `json:"rolledBack,omitempty"`
+	// We might add a Manifest copy later
 }
 
+// Resource is an object created in the colony by an install, such as the
+// process graph of a workflow or a registered function.
+type Resource struct {
+	// Kind is the spec kind, e.g. KindWorkflow or KindFunction
+	Kind string `json:"kind"`
+	Name string `json:"name,omitempty"`
+	// ID is the process graph ID of a workflow or the function ID of a
+	// function
+	ID string `json:"id"`
+	// Template is the template file the spec was rendered from, empty if
+	// it is not known, e.g. after a post-renderer changed the specs
+	Template string `json:"template,omitempty"`
+	// State is the last known state of a workflow, recorded by --wait
+	State string `json:"state,omitempty"`
+}
+
+// Release statuses
+const (
+	ReleaseDeployed = "deployed"
+	ReleaseFailed   = "failed"
+)
+
 // StateService defines operations for local state management
 type StateService interface {
-	Save(release *Release) error
-	List() ([]*Release, error)
-	Get(name string) (*Release, error)
-	Delete(name string) error
+	Save(ctx context.Context, release *Release) error
+	List(ctx context.Context) ([]*Release, error)
+	Get(ctx context.Context, name string) (*Release, error)
+	Delete(ctx context.Context, name string) error
 }
@@ -0,0 +1,76 @@
+// Package render renders CPM packages from other Go programs. Packages are
+// read through fs.FS, so they can be directories, .cpm archives or
+// packages embedded with embed.FS.
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/colonyos/cpm/internal/engine"
+	"github.com/colonyos/cpm/internal/infra/storage"
+	"github.com/colonyos/cpm/internal/patch"
+	"github.com/colonyos/cpm/pkg/domain"
+)
+
+// Options selects the values a package is rendered with.
+type Options struct {
+	// Env selects the values-<env>.yaml profile merged over values.yaml
+	Env string
+	// Values are deep-merged over the package values, like --set
+	Values map[string]interface{}
+	// Sandbox renders an untrusted package without the restricted template
+	// functions, as cpm template does for packages from a registry
+	Sandbox bool
+	// AllowedFunctions are the restricted functions a sandboxed render may
+	// still use, like --allow-functions
+	AllowedFunctions []string
+}
+
+// RenderFS renders the package rooted at fsys and returns the specs as a
+// JSON array. The values are the package's values.yaml, the Env profile
+// and then Values, and the patches of the Env profile are applied to the
+// rendered specs. Unlike cpm template it does not decrypt secrets.yaml.
+// Cancelling ctx aborts the render.
+func RenderFS(ctx context.Context, fsys fs.FS, opts Options) ([]byte, error) {
+	values, err := engine.LoadValuesFS(fsys, opts.Env)
+	if err != nil {
+		return nil, err
+	}
+	values = engine.CoalesceValues(values, opts.Values)
+
+	var renderer domain.TemplateEngine = engine.NewGoTemplateEngine()
+	if opts.Sandbox {
+		renderer = engine.NewGoTemplateEngine().Sandboxed(opts.AllowedFunctions)
+	}
+	rendered, err := renderer.RenderFS(ctx, fsys, values)
+	if err != nil || opts.Env == "" {
+		return rendered, err
+	}
+
+	patches, err := patch.LoadFS(fsys, engine.EnvValuesFile(opts.Env))
+	if err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return rendered, nil
+	}
+	var specs []map[string]interface{}
+	if err := json.Unmarshal(rendered, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse rendered templates as JSON: %w", err)
+	}
+	specs, err = patch.Apply(specs, patches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply patches: %w", err)
+	}
+	return json.Marshal(specs)
+}
+
+// ReadArchive reads a .cpm archive, as written by cpm pack, into a file
+// system that RenderFS can render.
+func ReadArchive(r io.Reader) (fs.FS, error) {
+	return storage.ReadArchive(r)
+}